@@ -0,0 +1,30 @@
+// Command fakepokeapi serves a small bundled copy of the PokeAPI, so the
+// Pokedex can be run without internet access:
+//
+//	go run ./cmd/fakepokeapi -addr localhost:8080
+//	go run ./cmd/pokedex -api-url http://localhost:8080/api/v2/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/fakepokeapi"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	flag.Parse()
+
+	handler := fakepokeapi.NewHandler()
+	fmt.Printf("Serving %s\n", strings.Join(handler.Endpoints(), ", "))
+	fmt.Printf("Run the pokedex with: -api-url http://%s%s\n", *addr, fakepokeapi.Prefix)
+	err := http.ListenAndServe(*addr, handler)
+	if err != nil {
+		fmt.Printf("Unable to serve: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// appConfig holds the user-tunable settings for the REPL.
+type appConfig struct {
+	APIURL      string   `json:"api_url"`
+	Timeout     duration `json:"timeout"`
+	MaxAttempts int      `json:"max_attempts"`
+	// MaxConcurrency caps how many requests bulk commands keep in flight.
+	MaxConcurrency int `json:"max_concurrency"`
+	// RateLimit is the most API requests per second, shared by every command.
+	RateLimit float64 `json:"rate_limit"`
+	// CacheMaxBytes and CacheMaxEntries bound the in-memory cache; zero is unlimited.
+	CacheMaxBytes   int64 `json:"cache_max_bytes"`
+	CacheMaxEntries int   `json:"cache_max_entries"`
+	// CacheCompressThreshold is the smallest response that is kept gzipped
+	// in memory; zero disables compression.
+	CacheCompressThreshold int `json:"cache_compress_threshold"`
+	// ListTTL and ResourceTTL are how long list pages and individual
+	// resources like a pokemon stay cached.
+	ListTTL     duration `json:"list_ttl"`
+	ResourceTTL duration `json:"resource_ttl"`
+	// StaleTTL is how long past expiry a cached response may still be shown
+	// while it is refreshed in the background. Zero disables it.
+	StaleTTL duration `json:"stale_ttl"`
+	// CacheMaxAge is how long a response is kept on disk after it was last
+	// fetched, so it can be shown offline long after expiring. Zero keeps
+	// responses until the cache is purged.
+	CacheMaxAge duration `json:"cache_max_age"`
+	// Offline starts the REPL without network access, answering from the cache only.
+	Offline bool `json:"offline"`
+	// DebugLog is a file to write debug output to, or "-" for stderr.
+	DebugLog string `json:"debug_log"`
+}
+
+// duration is a time.Duration written as a string like "10s" in the config file.
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// defaultConfig holds the settings used when nothing overrides them.
+func defaultConfig() appConfig {
+	return appConfig{
+		Timeout:        duration{15 * time.Second},
+		MaxAttempts:    4,
+		MaxConcurrency: 8,
+		RateLimit:      20,
+		CacheMaxBytes:  64 << 20,
+		// Pokemon bodies are hundreds of KB of repetitive JSON
+		CacheCompressThreshold: 16 << 10,
+		ListTTL:                duration{time.Hour},
+		ResourceTTL:            duration{7 * 24 * time.Hour},
+		StaleTTL:               duration{24 * time.Hour},
+		CacheMaxAge:            duration{30 * 24 * time.Hour},
+	}
+}
+
+// loadConfig builds the settings from, in increasing order of precedence,
+// the config file, environment variables and command line flags. It also
+// returns the arguments left after the flags, such as a subcommand. The
+// default config file is read from configDir, if it isn't empty.
+func loadConfig(args []string, configDir string) (appConfig, []string, error) {
+	cfg := defaultConfig()
+
+	// The defaults are only shown in -h; the flags are applied below, and
+	// only if they were given
+	defaults := defaultConfig()
+	flags := flag.NewFlagSet("pokedex", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to a JSON config file")
+	apiURL := flags.String("api-url", "", "base URL of the PokeAPI to use (env POKEDEX_API_URL)")
+	timeout := flags.Duration("timeout", defaults.Timeout.Duration, "maximum time to wait for a single API request (0 waits forever)")
+	maxAttempts := flags.Int("max-attempts", defaults.MaxAttempts, "how many times to try a failing API request (0 or 1 disables retries)")
+	maxConcurrency := flags.Int("max-concurrency", defaults.MaxConcurrency, "how many API requests bulk commands keep in flight")
+	rateLimit := flags.Float64("rate-limit", defaults.RateLimit, "most API requests per second (0 disables the limit)")
+	cacheMaxBytes := flags.Int64("cache-max-bytes", defaults.CacheMaxBytes, "most memory the response cache may use (0 is unlimited)")
+	cacheMaxEntries := flags.Int("cache-max-entries", defaults.CacheMaxEntries, "most responses the cache may hold in memory (0 is unlimited)")
+	compressThreshold := flags.Int("cache-compress-threshold", defaults.CacheCompressThreshold, "smallest response to compress in memory (0 disables)")
+	staleTTL := flags.Duration("stale-ttl", defaults.StaleTTL.Duration, "how long past expiry cached responses may be shown while refreshing (0 disables)")
+	cacheMaxAge := flags.Duration("cache-max-age", defaults.CacheMaxAge.Duration, "how long responses are kept on disk after they were fetched (0 keeps them)")
+	offline := flags.Bool("offline", false, "never touch the network; only show what is already cached")
+	debugLog := flags.String("debug-log", "", `file to write debug output to, or "-" for stderr`)
+	err := flags.Parse(args)
+	if err != nil {
+		return cfg, nil, err
+	}
+	// The defaults are all at least zero, so only a flag can be negative
+	for _, check := range []struct {
+		name     string
+		negative bool
+	}{
+		{"timeout", *timeout < 0},
+		{"max-attempts", *maxAttempts < 0},
+		{"max-concurrency", *maxConcurrency < 0},
+		{"rate-limit", *rateLimit < 0},
+		{"cache-max-bytes", *cacheMaxBytes < 0},
+		{"cache-max-entries", *cacheMaxEntries < 0},
+		{"cache-compress-threshold", *compressThreshold < 0},
+		{"stale-ttl", *staleTTL < 0},
+		{"cache-max-age", *cacheMaxAge < 0},
+	} {
+		if check.negative {
+			return cfg, nil, fmt.Errorf("-%s must not be negative", check.name)
+		}
+	}
+
+	if configDir != "" {
+		err = readConfigFile(filepath.Join(configDir, "pokedex-go", "config.json"), &cfg)
+		if err != nil {
+			return cfg, nil, err
+		}
+	}
+	// An explicit config file overlays the default one, so it must exist
+	if *configPath != "" {
+		_, err = os.Stat(*configPath)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("error reading config file: %w", err)
+		}
+		err = readConfigFile(*configPath, &cfg)
+		if err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	envURL := os.Getenv("POKEDEX_API_URL")
+	if envURL != "" {
+		cfg.APIURL = envURL
+	}
+
+	// Only flags given on the command line override the files and
+	// environment, so they can also switch a setting back off
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "api-url":
+			cfg.APIURL = *apiURL
+		case "offline":
+			cfg.Offline = *offline
+		case "debug-log":
+			cfg.DebugLog = *debugLog
+		case "timeout":
+			cfg.Timeout.Duration = *timeout
+		case "max-attempts":
+			cfg.MaxAttempts = *maxAttempts
+		case "max-concurrency":
+			cfg.MaxConcurrency = *maxConcurrency
+		case "rate-limit":
+			cfg.RateLimit = *rateLimit
+		case "cache-max-bytes":
+			cfg.CacheMaxBytes = *cacheMaxBytes
+		case "cache-max-entries":
+			cfg.CacheMaxEntries = *cacheMaxEntries
+		case "cache-compress-threshold":
+			cfg.CacheCompressThreshold = *compressThreshold
+		case "stale-ttl":
+			cfg.StaleTTL.Duration = *staleTTL
+		case "cache-max-age":
+			cfg.CacheMaxAge.Duration = *cacheMaxAge
+		}
+	})
+	return cfg, flags.Args(), nil
+}
+
+// readConfigFile overlays the settings in path onto cfg. A missing file is ignored.
+func readConfigFile(path string, cfg *appConfig) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// openDebugLog returns a logger for debug output, discarding it if no log is configured.
+func openDebugLog(path string) (*log.Logger, error) {
+	switch path {
+	case "":
+		return log.New(io.Discard, "", 0), nil
+	case "-":
+		return log.New(os.Stderr, "debug: ", log.LstdFlags), nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening debug log: %w", err)
+	}
+	return log.New(file, "", log.LstdFlags), nil
+}
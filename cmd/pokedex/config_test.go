@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		name string
+		// defaultFile and explicitFile are the contents of the default config
+		// file and of one passed with -config; empty means there is none.
+		defaultFile  string
+		explicitFile string
+		env          string
+		args         []string
+		// wantArgs is what should be left after the flags.
+		wantArgs []string
+		check    func(t *testing.T, cfg appConfig)
+		wantErr  bool
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.APIURL != "" || cfg.Timeout.Duration != 15*time.Second || cfg.ListTTL.Duration != time.Hour {
+					t.Errorf("unexpected defaults: %+v", cfg)
+				}
+			},
+		},
+		{
+			name:        "default file",
+			defaultFile: `{"api_url": "http://file/", "timeout": "3s", "resource_ttl": "48h"}`,
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.APIURL != "http://file/" || cfg.Timeout.Duration != 3*time.Second || cfg.ResourceTTL.Duration != 48*time.Hour {
+					t.Errorf("expected the file's settings, got %+v", cfg)
+				}
+				if cfg.MaxAttempts != 4 {
+					t.Errorf("expected settings missing from the file to keep their defaults, got %d", cfg.MaxAttempts)
+				}
+			},
+		},
+		{
+			name:         "explicit file overlays default file",
+			defaultFile:  `{"api_url": "http://default/", "max_attempts": 2}`,
+			explicitFile: `{"api_url": "http://explicit/"}`,
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.APIURL != "http://explicit/" || cfg.MaxAttempts != 2 {
+					t.Errorf("expected the explicit file over the default one, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name:         "env beats files",
+			defaultFile:  `{"api_url": "http://default/"}`,
+			explicitFile: `{"api_url": "http://explicit/"}`,
+			env:          "http://env/",
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.APIURL != "http://env/" {
+					t.Errorf("expected the environment to win, got %s", cfg.APIURL)
+				}
+			},
+		},
+		{
+			name:         "flags beat env and files",
+			explicitFile: `{"api_url": "http://explicit/", "timeout": "3s"}`,
+			env:          "http://env/",
+			args:         []string{"-api-url", "http://flag/", "-timeout", "5s"},
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.APIURL != "http://flag/" || cfg.Timeout.Duration != 5*time.Second {
+					t.Errorf("expected the flags to win, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name:        "zero flags override file and defaults",
+			defaultFile: `{"stale_ttl": "1h", "cache_max_age": "48h", "rate_limit": 5}`,
+			args: []string{
+				"-stale-ttl", "0", "-cache-max-age", "0", "-timeout", "0", "-max-attempts", "0",
+				"-rate-limit", "0", "-cache-max-bytes", "0", "-cache-max-entries", "0",
+			},
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.StaleTTL.Duration != 0 || cfg.CacheMaxAge.Duration != 0 || cfg.Timeout.Duration != 0 || cfg.MaxAttempts != 0 {
+					t.Errorf("expected zero flags to be applied, got %+v", cfg)
+				}
+				if cfg.RateLimit != 0 || cfg.CacheMaxBytes != 0 || cfg.CacheMaxEntries != 0 {
+					t.Errorf("expected zero flags to lift the limits, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name:        "flags switch file settings off",
+			defaultFile: `{"api_url": "http://file/", "offline": true, "debug_log": "debug.log"}`,
+			args:        []string{"-offline=false", "-api-url", "", "-debug-log", ""},
+			check: func(t *testing.T, cfg appConfig) {
+				if cfg.Offline || cfg.APIURL != "" || cfg.DebugLog != "" {
+					t.Errorf("expected the flags to clear the file's settings, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name:     "arguments after flags",
+			args:     []string{"-offline", "sync"},
+			wantArgs: []string{"sync"},
+			check: func(t *testing.T, cfg appConfig) {
+				if !cfg.Offline {
+					t.Errorf("expected -offline to be set")
+				}
+			},
+		},
+		{
+			name:    "negative flag",
+			args:    []string{"-timeout", "-5s"},
+			wantErr: true,
+		},
+		{
+			name:    "missing explicit file",
+			args:    []string{"-config", "does-not-exist.json"},
+			wantErr: true,
+		},
+		{
+			name:         "invalid JSON",
+			explicitFile: `{"api_url": `,
+			wantErr:      true,
+		},
+		{
+			name:        "invalid duration",
+			defaultFile: `{"timeout": "soon"}`,
+			wantErr:     true,
+		},
+		{
+			name:        "duration that isn't a string",
+			defaultFile: `{"timeout": 10}`,
+			wantErr:     true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configDir := t.TempDir()
+			t.Setenv("POKEDEX_API_URL", c.env)
+			if c.defaultFile != "" {
+				path := filepath.Join(configDir, "pokedex-go", "config.json")
+				os.MkdirAll(filepath.Dir(path), 0o755)
+				err := os.WriteFile(path, []byte(c.defaultFile), 0o644)
+				if err != nil {
+					t.Fatalf("unable to write config file: %v", err)
+				}
+			}
+			args := c.args
+			if c.explicitFile != "" {
+				path := filepath.Join(t.TempDir(), "explicit.json")
+				err := os.WriteFile(path, []byte(c.explicitFile), 0o644)
+				if err != nil {
+					t.Fatalf("unable to write config file: %v", err)
+				}
+				args = append([]string{"-config", path}, args...)
+			}
+
+			cfg, rest, err := loadConfig(args, configDir)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(rest, c.wantArgs) {
+				t.Errorf("expected %v to be left over, got %v", c.wantArgs, rest)
+			}
+			c.check(t, cfg)
+		})
+	}
+}
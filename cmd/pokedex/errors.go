@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+)
+
+// printAPIError explains a failed API request in terms the user can act on.
+// Commands handle the errors that need their own wording, such as a pokemon
+// that doesn't exist, before falling back to this.
+func printAPIError(err error) {
+	var statusErr *pokeapi.HTTPStatusError
+	var decodeErr *pokeapi.DecodeError
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The interrupter has already told the user we're cancelling
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Println("The PokeAPI took too long to answer...try again.")
+	case errors.Is(err, pokeapi.ErrOffline):
+		fmt.Println("That isn't cached, so it can't be shown offline...try again.")
+	case errors.Is(err, pokeapi.ErrRateLimited):
+		fmt.Println("The PokeAPI is busy. Wait a moment...try again.")
+	case errors.Is(err, pokeapi.ErrNotFound):
+		fmt.Println("The PokeAPI doesn't know about that...try again.")
+	case errors.As(err, &statusErr):
+		fmt.Printf("The PokeAPI answered %s...try again.\n", statusErr.Status)
+	case errors.As(err, &decodeErr):
+		fmt.Println("The PokeAPI sent a response we couldn't read...try again.")
+	default:
+		fmt.Printf("Unable to reach the PokeAPI: %v\n", err)
+	}
+}
+
+// failureReason describes a failed API request in a few words, for flagging
+// one item in a list.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, pokeapi.ErrOffline):
+		return "not cached"
+	case errors.Is(err, pokeapi.ErrNotFound):
+		return "not found"
+	case errors.Is(err, pokeapi.ErrRateLimited):
+		return "rate limited"
+	default:
+		return "couldn't be fetched"
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// interrupter turns Ctrl-C into cancellation of the running command, so a
+// hung request drops back to the prompt instead of killing the REPL.
+type interrupter struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+}
+
+func newInterrupter() *interrupter {
+	in := &interrupter{}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		for range sigCh {
+			in.mu.Lock()
+			if in.cancel != nil {
+				fmt.Println("\nCancelling...")
+				in.cancel()
+			} else {
+				fmt.Print("\nType exit to close the Pokedex.\nPokedex > ")
+			}
+			in.mu.Unlock()
+		}
+	}()
+	return in
+}
+
+// commandContext returns a context that the next Ctrl-C cancels, and a func
+// to call once the command has finished.
+func (in *interrupter) commandContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in.mu.Lock()
+	in.cancel = cancel
+	in.mu.Unlock()
+	return ctx, func() {
+		in.mu.Lock()
+		in.cancel = nil
+		in.mu.Unlock()
+		cancel()
+	}
+}
@@ -2,62 +2,178 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
 	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
 	"github.com/curtisbraxdale/pokedex-go/internal/utils"
 )
 
 var dex = utils.Pokedex{Pokemon: make(map[string]utils.Pokemon)}
 
+// savePath is the default save file. Unlike dex.Path it is set even when the
+// save couldn't be loaded, so an explicit save or load can still use it.
+var savePath string
+
 func main() {
+	// Without a config dir there's just no default config file to read
+	configDir, _ := os.UserConfigDir()
+	appCfg, args, err := loadConfig(os.Args[1:], configDir)
+	if err != nil {
+		fmt.Printf("Unable to load config: %v\n", err)
+		os.Exit(2)
+	}
+
+	logger, err := openDebugLog(appCfg.DebugLog)
+	if err != nil {
+		fmt.Printf("Unable to open debug log: %v\n", err)
+		os.Exit(2)
+	}
+
 	interval := time.Hour
-	cache := pokecache.NewCache(interval)
+	// Keep responses on disk too so new sessions don't re-download everything
+	cacheOpts := []pokecache.Option{
+		pokecache.WithMaxBytes(appCfg.CacheMaxBytes),
+		pokecache.WithMaxEntries(appCfg.CacheMaxEntries),
+		pokecache.WithStaleTTL(appCfg.StaleTTL.Duration),
+		pokecache.WithCompression(appCfg.CacheCompressThreshold),
+		pokecache.WithDiskMaxAge(appCfg.CacheMaxAge.Duration),
+		pokecache.WithLogger(logger),
+	}
+	cacheDir, err := pokecache.DefaultDir()
+	if err != nil {
+		fmt.Printf("Unable to locate cache dir: %v\n", err)
+	} else {
+		cacheOpts = append(cacheOpts, pokecache.WithDiskDir(cacheDir))
+	}
+	cache := pokecache.NewCache(interval, cacheOpts...)
+	cache.OnEvict(func(key string, val []byte, reason pokecache.EvictReason) {
+		logger.Printf("evicted %s from the cache (%v)", key, reason)
+	})
+	client := pokeapi.NewClient(appCfg.APIURL, cache,
+		pokeapi.WithTimeout(appCfg.Timeout.Duration),
+		pokeapi.WithMaxAttempts(appCfg.MaxAttempts),
+		pokeapi.WithMaxConcurrency(appCfg.MaxConcurrency),
+		// Allow a page's worth of requests to go out at once
+		pokeapi.WithRateLimit(appCfg.RateLimit, 20),
+		pokeapi.WithCacheTTLs(appCfg.ListTTL.Duration, appCfg.ResourceTTL.Duration),
+		pokeapi.WithStaleWhileRevalidate(appCfg.StaleTTL.Duration > 0),
+		pokeapi.WithLogger(logger),
+		pokeapi.WithOffline(appCfg.Offline),
+	)
+	if len(args) > 0 {
+		os.Exit(runSubcommand(client, cache, args))
+	}
+	if client.Offline() {
+		fmt.Println("Offline mode: only cached data is available.")
+	}
+
+	// Restore any pokemon caught in a previous session
+	path, err := utils.DefaultPokedexPath()
+	if err != nil {
+		fmt.Printf("Unable to locate pokedex save file: %v\n", err)
+	} else {
+		restoreDex(path)
+	}
 
 	//Initialize the config struct with the first url set
-	initialUrl := "https://pokeapi.co/api/v2/location-area/"
+	initialUrl := client.LocationAreasURL()
 	initialPtr := &initialUrl
 	config := utils.UrlConfig{
 		Next:     initialPtr,
 		Previous: nil,
 	}
 
+	interrupts := newInterrupter()
 	scanner := bufio.NewScanner(os.Stdin)
 	for {
 		fmt.Print("Pokedex > ")
-		scanner.Scan()
+		if !scanner.Scan() {
+			// Ctrl-D or the end of piped input
+			fmt.Println()
+			commandExit(cache)
+		}
 		input := scanner.Text()
 		cleanedInput := cleanInput(input)
+		if len(cleanedInput) == 0 {
+			continue
+		}
 		command := cleanedInput[0]
+		ctx, done := interrupts.commandContext()
 		switch command {
 		case "exit":
-			commandExit()
+			commandExit(cache)
 		case "help":
 			commandHelp()
 		case "map":
-			commandMap(&config, cache)
+			commandMap(ctx, client, &config)
 		case "mapb":
-			commandMapb(&config, cache)
+			commandMapb(ctx, client, &config)
 		case "explore":
-			commandExplore(cleanedInput[1], cache)
+			commandExplore(ctx, client, cleanedInput[1])
 		case "catch":
-			commandCatch(cleanedInput[1], cache)
+			commandCatch(ctx, client, cleanedInput[1])
 		case "inspect":
 			commandInspect(cleanedInput[1])
 		case "pokedex":
 			commandPokedex()
+		case "save":
+			commandSave()
+		case "cache":
+			// Cache keys are URLs, so keep the raw input for prefixes
+			commandCache(cache, strings.Fields(input)[1:])
+		case "sync":
+			commandSync(ctx, client)
+		case "offline":
+			commandOffline(client, cleanedInput[1:])
+		case "load":
+			if len(cleanedInput) < 2 {
+				fmt.Println("Usage: load <path>")
+				break
+			}
+			// Paths are case sensitive, so use the raw input rather than the lowered one
+			commandLoad(strings.Fields(input)[1])
 		default:
 			fmt.Printf("Unknown command: %v\n", command)
 		}
+		done()
+		// Save the cache's index now rather than waiting for the reaper, so
+		// what this command fetched is on disk however the REPL ends
+		err = cache.Flush()
+		if err != nil {
+			logger.Printf("unable to flush cache: %v", err)
+		}
+	}
+}
+
+// runSubcommand runs a command given on the command line, such as
+// `pokedex sync`, instead of starting the REPL. It returns the exit code.
+func runSubcommand(client *pokeapi.Client, cache *pokecache.Cache, args []string) int {
+	defer cache.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	switch args[0] {
+	case "sync":
+		err := commandSync(ctx, client)
+		if err != nil {
+			return 1
+		}
+		return 0
+	default:
+		fmt.Printf("Unknown subcommand: %v\n", args[0])
+		return 2
 	}
 }
 
-func commandExit() error {
+func commandExit(cache *pokecache.Cache) error {
 	fmt.Print("Closing the Pokedex... Goodbye!")
+	cache.Close()
 	os.Exit(0)
 	return nil
 }
@@ -72,57 +188,102 @@ func commandHelp() error {
 	fmt.Printf("catch - Attempt to catch a given pokemon\n")
 	fmt.Printf("inspect - Shows the pokedex entry of a caught pokemon\n")
 	fmt.Printf("pokedex - Displays a list of caught pokemon\n")
+	fmt.Printf("save - Saves your pokedex to disk\n")
+	fmt.Printf("load <path> - Loads a pokedex from a save file\n")
+	fmt.Printf("cache stats|list|purge|evict <prefix> - Inspects or clears the response cache\n")
+	fmt.Printf("sync - Downloads every location area and pokemon for offline use\n")
+	fmt.Printf("offline on|off - Stops or resumes using the network\n")
 	fmt.Printf("exit - Exits the pokedex\n")
 	return nil
 }
 
-func commandMap(config *utils.UrlConfig, cache *pokecache.Cache) error {
-	areas, err := utils.GetLocationAreas(config, "forward", cache)
-	if err != nil {
-		return err
-	}
-	for i := 0; i < len(areas); i++ {
-		fmt.Println(areas[i].Name)
+func commandMap(ctx context.Context, client *pokeapi.Client, config *utils.UrlConfig) error {
+	if config.Next != nil {
+		return showLocationAreas(ctx, client, config, "forward")
+	} else {
+		fmt.Println("No next page available...try again.")
+		return errors.New("No next page available.")
 	}
-	return nil
 }
 
-func commandMapb(config *utils.UrlConfig, cache *pokecache.Cache) error {
+func commandMapb(ctx context.Context, client *pokeapi.Client, config *utils.UrlConfig) error {
 	if config.Previous != nil {
-		areas, err := utils.GetLocationAreas(config, "backward", cache)
-		if err != nil {
-			return err
-		}
-		for i := 0; i < len(areas); i++ {
-			fmt.Println(areas[i].Name)
-		}
-		return nil
+		return showLocationAreas(ctx, client, config, "backward")
 	} else {
 		fmt.Println("No previous page available...try again.")
 		return errors.New("No previous page available.")
 	}
 }
 
-func commandExplore(location string, cache *pokecache.Cache) error {
-	pokemonList, err := utils.ExploreArea(location, cache)
+// showLocationAreas prints the next or previous page of location areas,
+// flagging any whose details couldn't be fetched.
+func showLocationAreas(ctx context.Context, client *pokeapi.Client, config *utils.UrlConfig, direction string) error {
+	page, err := utils.GetLocationAreas(ctx, client, config, direction)
+	if errors.Is(err, pokeapi.ErrOffline) {
+		fmt.Println("That page isn't cached, so it can't be shown offline...try again.")
+		return err
+	}
 	if err != nil {
+		printAPIError(err)
 		return err
 	}
+	for _, name := range page.Names {
+		if failErr, failed := page.Failed[name]; failed {
+			fmt.Printf("%s (%s)\n", name, failureReason(failErr))
+			continue
+		}
+		fmt.Println(name)
+	}
+	if page.Err != nil {
+		fmt.Printf("%d of %d location areas couldn't be fetched, so they can't be explored yet.\n", len(page.Failed), len(page.Names))
+	}
+	return page.Err
+}
+
+func commandExplore(ctx context.Context, client *pokeapi.Client, location string) error {
+	fmt.Printf("Exploring area: %s\n", location)
+	pokemonList, err := utils.ExploreArea(ctx, client, location)
+	if errors.Is(err, pokeapi.ErrNotFound) {
+		fmt.Printf("%s is not a location area...try again.\n", location)
+		return err
+	}
+	if errors.Is(err, pokeapi.ErrOffline) {
+		fmt.Printf("%s isn't cached, so it can't be explored offline...try again.\n", location)
+		return err
+	}
+	if err != nil {
+		printAPIError(err)
+		return err
+	}
+	fmt.Printf("Found %d pokemon encounters in the area.\n", len(pokemonList))
 	for i := 0; i < len(pokemonList); i++ {
 		fmt.Println(pokemonList[i].Pokemon.Name)
 	}
 	return nil
 }
 
-func commandCatch(pokemon string, cache *pokecache.Cache) error {
-	pokemonDetails, caught, err := utils.CatchPokemon(pokemon, cache)
-	if err != nil {
+func commandCatch(ctx context.Context, client *pokeapi.Client, pokemon string) error {
+	pokemonDetails, caught, err := utils.CatchPokemon(ctx, client, pokemon)
+	if errors.Is(err, pokeapi.ErrNotFound) {
 		fmt.Printf("%s is not a pokemon...try again.\n", pokemon)
 		return err
 	}
+	if errors.Is(err, pokeapi.ErrOffline) {
+		fmt.Printf("%s isn't cached, so it can't be caught offline...try again.\n", pokemon)
+		return err
+	}
+	if err != nil {
+		printAPIError(err)
+		return err
+	}
+	fmt.Printf("Throwing a Pokeball at %s (%d%% chance)...\n", pokemonDetails.Name, utils.CatchChance(*pokemonDetails))
 	if caught {
 		fmt.Printf("Congratulations! You caught %s!\n", pokemonDetails.Name)
-		utils.AddToDex(pokemonDetails, &dex)
+		err = utils.AddToDex(pokemonDetails, &dex)
+		if err != nil {
+			fmt.Printf("Unable to save pokedex: %v\n", err)
+			return err
+		}
 	} else {
 		fmt.Printf("Oh no! %s escaped!\n", pokemonDetails.Name)
 	}
@@ -160,6 +321,129 @@ func commandPokedex() {
 	}
 }
 
+// restoreDex loads the pokedex saved at path. Catches are only saved
+// automatically once it has loaded, so a save that can't be read (say, one
+// written by a newer version) isn't overwritten by the next catch.
+func restoreDex(path string) error {
+	savePath = path
+	err := dex.Load(path)
+	if err != nil {
+		fmt.Printf("Unable to load pokedex: %v\n", err)
+		fmt.Println("Catches won't be saved until you run save or load.")
+		return err
+	}
+	dex.Path = path
+	return nil
+}
+
+func commandSave() error {
+	path := dex.Path
+	if path == "" {
+		path = savePath
+	}
+	if path == "" {
+		fmt.Println("No save file available...try again.")
+		return errors.New("No save file available.")
+	}
+	err := dex.Save(path)
+	if err != nil {
+		fmt.Printf("Unable to save pokedex: %v\n", err)
+		return err
+	}
+	dex.Path = path
+	fmt.Printf("Saved %d pokemon to %s\n", len(dex.Pokemon), dex.Path)
+	return nil
+}
+
+func commandLoad(path string) error {
+	// Load into a scratch pokedex first so a bad file doesn't wipe the current one
+	loaded := utils.Pokedex{}
+	_, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("%s is not a save file...try again.\n", path)
+		return err
+	}
+	err = loaded.Load(path)
+	if err != nil {
+		fmt.Printf("Unable to load pokedex: %v\n", err)
+		return err
+	}
+	dex.Pokemon = loaded.Pokemon
+	fmt.Printf("Loaded %d pokemon from %s\n", len(dex.Pokemon), path)
+	if dex.Path == "" {
+		dex.Path = savePath
+	}
+	if dex.Path != "" {
+		err = dex.Save(dex.Path)
+		if err != nil {
+			fmt.Printf("Unable to save pokedex: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func commandCache(cache *pokecache.Cache, args []string) error {
+	if len(args) == 0 {
+		fmt.Println("Usage: cache stats|list|purge|evict <prefix>")
+		return errors.New("No cache subcommand given.")
+	}
+	switch strings.ToLower(args[0]) {
+	case "stats":
+		stats := cache.Stats()
+		fmt.Printf("Hits: %v\n", stats.Hits)
+		fmt.Printf("Misses: %v\n", stats.Misses)
+		fmt.Printf("Entries: %v (%v on disk)\n", stats.Entries, stats.DiskEntries)
+		fmt.Printf("Bytes: %v\n", stats.Bytes)
+		fmt.Printf("Evictions: %v\n", stats.Evictions)
+		fmt.Printf("Expirations: %v\n", stats.Expirations)
+		fmt.Printf("Oldest entry: %v\n", stats.OldestAge.Round(time.Second))
+	case "list":
+		for _, entry := range cache.Entries() {
+			status := "fresh"
+			if !entry.Fresh {
+				status = "stale"
+			}
+			fmt.Printf("	-%v (%v bytes, %v old, %v)\n", entry.Key, entry.Bytes, entry.Age.Round(time.Second), status)
+		}
+	case "purge":
+		fmt.Printf("Removed %d entries from the cache.\n", cache.Purge())
+	case "evict":
+		if len(args) < 2 {
+			fmt.Println("Usage: cache evict <prefix>")
+			return errors.New("No prefix given.")
+		}
+		fmt.Printf("Removed %d entries from the cache.\n", cache.EvictPrefix(args[1]))
+	default:
+		fmt.Printf("Unknown cache subcommand: %v\n", args[0])
+		return errors.New("Unknown cache subcommand.")
+	}
+	return nil
+}
+
+func commandOffline(client *pokeapi.Client, args []string) error {
+	if len(args) == 0 {
+		if client.Offline() {
+			fmt.Println("Offline mode is on.")
+		} else {
+			fmt.Println("Offline mode is off.")
+		}
+		return nil
+	}
+	switch args[0] {
+	case "on":
+		client.SetOffline(true)
+		fmt.Println("Offline mode is on. Only cached data will be shown.")
+	case "off":
+		client.SetOffline(false)
+		fmt.Println("Offline mode is off.")
+	default:
+		fmt.Println("Usage: offline on|off")
+		return errors.New("Unknown offline setting.")
+	}
+	return nil
+}
+
 func cleanInput(text string) []string {
 	loweredText := strings.ToLower(text)
 	return strings.Fields(loweredText)
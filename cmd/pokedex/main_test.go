@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/utils"
+)
+
+func TestRestoreDexFailureKeepsSave(t *testing.T) {
+	t.Cleanup(func() {
+		dex = utils.Pokedex{Pokemon: make(map[string]utils.Pokemon)}
+		savePath = ""
+	})
+	// A save written by some newer version this one can't read
+	path := filepath.Join(t.TempDir(), "pokedex.json")
+	original := []byte(`{"version": 99, "pokemon": {"mew": {"id": 151, "name": "mew"}}}`)
+	err := os.WriteFile(path, original, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = restoreDex(path)
+	if err == nil {
+		t.Fatalf("expected an error loading the save")
+	}
+	// Catching something must not overwrite the save it couldn't read
+	err = utils.AddToDex(&utils.Pokemon{ID: 25, Name: "pikachu"}, &dex)
+	if err != nil {
+		t.Fatalf("unexpected error adding to dex: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(original) {
+		t.Errorf("expected the save to be left alone, got %s", data)
+	}
+
+	// until the user saves explicitly
+	err = commandSave()
+	if err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	loaded := utils.Pokedex{}
+	err = loaded.Load(path)
+	if err != nil || loaded.Pokemon["pikachu"].ID != 25 {
+		t.Errorf("expected an explicit save to write the pokedex, got %v, %v", loaded.Pokemon, err)
+	}
+}
+
+func TestRestoreDexSavesCatches(t *testing.T) {
+	t.Cleanup(func() {
+		dex = utils.Pokedex{Pokemon: make(map[string]utils.Pokemon)}
+		savePath = ""
+	})
+	path := filepath.Join(t.TempDir(), "pokedex.json")
+
+	err := restoreDex(path)
+	if err != nil {
+		t.Fatalf("unexpected error restoring a missing save: %v", err)
+	}
+	err = utils.AddToDex(&utils.Pokemon{ID: 25, Name: "pikachu"}, &dex)
+	if err != nil {
+		t.Fatalf("unexpected error adding to dex: %v", err)
+	}
+	loaded := utils.Pokedex{}
+	err = loaded.Load(path)
+	if err != nil || loaded.Pokemon["pikachu"].ID != 25 {
+		t.Errorf("expected the catch to be saved, got %v, %v", loaded.Pokemon, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+	"github.com/curtisbraxdale/pokedex-go/internal/utils"
+)
+
+// syncStatePath returns where sync records its progress, next to the cache
+// it fills. An empty path means the cache isn't on disk.
+func syncStatePath() string {
+	cacheDir, err := pokecache.DefaultDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "sync.json")
+}
+
+func commandSync(ctx context.Context, client *pokeapi.Client) error {
+	if client.Offline() {
+		fmt.Println("Can't sync while offline...try again.")
+		return pokeapi.ErrOffline
+	}
+	statePath := syncStatePath()
+	if statePath == "" {
+		fmt.Println("Warning: there is no disk cache, so synced data won't outlive this session.")
+	}
+
+	state := utils.LoadSyncState(statePath)
+	bar := progressBar{}
+	err := utils.Sync(ctx, client, state, bar.update)
+	bar.finish()
+	if errors.Is(err, context.Canceled) {
+		fmt.Println("Sync interrupted. Run sync again to pick up where it left off.")
+		return err
+	}
+	if err != nil {
+		fmt.Print("Sync failed. ")
+		printAPIError(err)
+		fmt.Println("Run sync again to pick up where it left off.")
+		return err
+	}
+	fmt.Println("Sync complete. Everything is cached for offline use.")
+	return nil
+}
+
+// progressBar draws sync progress on a single terminal line, with an ETA
+// based on how quickly resources have been fetched since the bar started.
+type progressBar struct {
+	resource  string
+	start     time.Time
+	startDone int
+	drawn     bool
+}
+
+const progressBarWidth = 30
+
+func (b *progressBar) update(p utils.SyncProgress) {
+	if p.Resource != b.resource {
+		// Each endpoint gets its own line and its own rate
+		b.finish()
+		b.resource = p.Resource
+		b.start = time.Now()
+		b.startDone = p.Done - 1
+	}
+
+	filled := 0
+	if p.Total > 0 {
+		filled = min(progressBarWidth, progressBarWidth*p.Done/p.Total)
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+	fmt.Printf("\r%-14s [%s] %d/%d  ETA %-10v", p.Resource, bar, p.Done, p.Total, b.eta(p))
+	b.drawn = true
+}
+
+// eta estimates the time left from the rate so far in this run. Resources
+// synced in an earlier run don't count, as they took no time at all.
+func (b *progressBar) eta(p utils.SyncProgress) time.Duration {
+	elapsed := time.Since(b.start)
+	fetched := p.Done - b.startDone
+	if fetched <= 0 || elapsed <= 0 || p.Total <= p.Done {
+		return 0
+	}
+	perResource := elapsed / time.Duration(fetched)
+	return (perResource * time.Duration(p.Total-p.Done)).Round(time.Second)
+}
+
+// finish moves past the bar, if one has been drawn.
+func (b *progressBar) finish() {
+	if b.drawn {
+		fmt.Println()
+		b.drawn = false
+	}
+}
@@ -0,0 +1,37 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.json")
+	for _, want := range []string{"first", "second"} {
+		err := Write(path, []byte(want))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != want {
+			t.Errorf("expected %q, got %q, %v", want, data, err)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected no temp files to be left behind, got %d files", len(files))
+	}
+}
+
+func TestWriteMissingDir(t *testing.T) {
+	err := Write(filepath.Join(t.TempDir(), "missing", "file.json"), []byte("data"))
+	if err == nil {
+		t.Errorf("expected an error writing into a missing dir")
+	}
+}
@@ -0,0 +1,173 @@
+// Package cassette records HTTP interactions to a file and replays them, so
+// tests of code that talks to the PokeAPI run offline and deterministically.
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode says whether a Transport talks to the network or replays a cassette.
+type Mode int
+
+const (
+	// Replay serves responses from the cassette and fails any request it has
+	// no recording for.
+	Replay Mode = iota
+	// Record sends requests on to the real server and adds what comes back
+	// to the cassette, which Save writes out.
+	Record
+)
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request identifies a recorded request. Only GETs without bodies are
+// expected, so the method and URL are enough to match on.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Transport is an http.RoundTripper backed by a cassette file.
+type Transport struct {
+	path string
+	mode Mode
+	// base carries requests to the real server when recording.
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// New returns a Transport for the cassette at path. In Replay mode the
+// cassette must already exist. In Record mode requests go through base, or
+// http.DefaultTransport if base is nil, and any existing cassette is replaced.
+func New(path string, mode Mode, base http.RoundTripper) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{path: path, mode: mode, base: base}
+	if mode == Record {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+	err = json.Unmarshal(data, &t.interactions)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling cassette %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == Record {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, interaction := range t.interactions {
+		if interaction.Request.Method == req.Method && interaction.Request.URL == req.URL.String() {
+			return interaction.Response.toHTTP(req), nil
+		}
+	}
+	return nil, fmt.Errorf("cassette %s has no recording of %s %s", filepath.Base(t.path), req.Method, req.URL)
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	recorded := Response{Status: resp.StatusCode, Header: keepHeaders(resp.Header), Body: string(body)}
+	t.mu.Lock()
+	t.interactions = append(t.interactions, Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String()},
+		Response: recorded,
+	})
+	t.mu.Unlock()
+	return recorded.toHTTP(req), nil
+}
+
+// Save writes what has been recorded to the cassette file. It does nothing
+// in Replay mode.
+func (t *Transport) Save() error {
+	if t.mode != Record {
+		return nil
+	}
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t.interactions, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error marshaling cassette: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(t.path), 0o755)
+	if err != nil {
+		return fmt.Errorf("error creating cassette dir: %w", err)
+	}
+	err = os.WriteFile(t.path, data, 0o644)
+	if err != nil {
+		return fmt.Errorf("error saving cassette: %w", err)
+	}
+	return nil
+}
+
+// keepHeaders drops headers that only add noise to a cassette, such as
+// dates and server details, keeping the ones the client acts on.
+func keepHeaders(header http.Header) http.Header {
+	kept := http.Header{}
+	for _, name := range []string{"Content-Type", "ETag", "Last-Modified", "Retry-After"} {
+		for _, value := range header.Values(name) {
+			kept.Add(name, value)
+		}
+	}
+	return kept
+}
+
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,66 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Noise", "dropped")
+		w.Write([]byte(`{"name": "pikachu"}`))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := New(path, Record, server.Client().Transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := (&http.Client{Transport: recorder}).Get(server.URL + "/pokemon/pikachu/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	err = recorder.Save()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.Close()
+
+	// The server is gone, so this can only come from the cassette
+	player, err := New(path, Replay, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &http.Client{Transport: player}
+	resp, err = client.Get(server.URL + "/pokemon/pikachu/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != `{"name": "pikachu"}` {
+		t.Errorf("unexpected replay: %d %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("ETag") != `"v1"` || resp.Header.Get("X-Noise") != "" {
+		t.Errorf("unexpected headers: %v", resp.Header)
+	}
+
+	_, err = client.Get(server.URL + "/pokemon/eevee/")
+	if err == nil || !strings.Contains(err.Error(), "no recording") {
+		t.Errorf("expected an error for an unrecorded request, got %v", err)
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"), Replay, nil)
+	if err == nil {
+		t.Errorf("expected an error for a missing cassette")
+	}
+}
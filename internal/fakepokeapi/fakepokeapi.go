@@ -0,0 +1,219 @@
+// Package fakepokeapi serves a small, fixed subset of the PokeAPI, so the
+// Pokedex can be developed and tested without internet access.
+//
+// The data covers the location-area, pokemon, pokemon-species, type and move
+// endpoints. Resources can be fetched by name or ID, and list endpoints page
+// with offset and limit just like the real API.
+package fakepokeapi
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix is the path the API is served under, matching the real PokeAPI.
+const Prefix = "/api/v2/"
+
+// realBaseURL is what links in the bundled data point at. They are rewritten
+// to point back at whichever server is serving them.
+const realBaseURL = "https://pokeapi.co/api/v2/"
+
+// defaultLimit is the page size when a list request doesn't give one.
+const defaultLimit = 20
+
+//go:embed data/*.json
+var dataFiles embed.FS
+
+// resource is a single bundled resource, kept as the raw JSON it is served as.
+type resource struct {
+	id   int
+	name string
+	body []byte
+}
+
+// Handler serves the bundled data.
+type Handler struct {
+	// endpoints maps an endpoint such as "pokemon" to its resources, sorted by ID.
+	endpoints map[string][]resource
+}
+
+// NewHandler returns a Handler for the bundled data.
+func NewHandler() *Handler {
+	h := &Handler{endpoints: make(map[string][]resource)}
+	files, err := dataFiles.ReadDir("data")
+	if err != nil {
+		panic(fmt.Sprintf("error reading bundled data: %v", err))
+	}
+	for _, file := range files {
+		endpoint := strings.TrimSuffix(file.Name(), ".json")
+		resources, err := loadResources(path.Join("data", file.Name()))
+		if err != nil {
+			// The data is compiled in, so this only happens if it was edited badly
+			panic(fmt.Sprintf("error loading bundled %s data: %v", endpoint, err))
+		}
+		h.endpoints[endpoint] = resources
+	}
+	return h
+}
+
+func loadResources(name string) ([]resource, error) {
+	data, err := dataFiles.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var raw []json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]resource, 0, len(raw))
+	for _, body := range raw {
+		var header struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		}
+		err = json.Unmarshal(body, &header)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource{id: header.ID, name: header.Name, body: body})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].id < resources[j].id
+	})
+	return resources, nil
+}
+
+// Endpoints lists the endpoints that have data, such as "pokemon".
+func (h *Handler) Endpoints() []string {
+	var endpoints []string
+	for endpoint := range h.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, Prefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	resources, exists := h.endpoints[parts[0]]
+	if !exists || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseURL := baseURLFor(r)
+	if len(parts) == 1 {
+		h.serveList(w, r, baseURL, parts[0], resources)
+		return
+	}
+	for _, res := range resources {
+		if res.name == parts[1] || strconv.Itoa(res.id) == parts[1] {
+			writeJSON(w, bytes.ReplaceAll(res.body, []byte(realBaseURL), []byte(baseURL)))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// listPage mirrors the PokeAPI's NamedAPIResourceList.
+type listPage struct {
+	Count    int            `json:"count"`
+	Next     *string        `json:"next"`
+	Previous *string        `json:"previous"`
+	Results  []listResource `json:"results"`
+}
+
+type listResource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request, baseURL, endpoint string, resources []resource) {
+	offset := queryInt(r, "offset", 0)
+	limit := queryInt(r, "limit", defaultLimit)
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	page := listPage{Count: len(resources), Results: []listResource{}}
+	for i := offset; i < min(offset+limit, len(resources)); i++ {
+		page.Results = append(page.Results, listResource{
+			Name: resources[i].name,
+			URL:  fmt.Sprintf("%s%s/%d/", baseURL, endpoint, resources[i].id),
+		})
+	}
+	if offset+limit < len(resources) {
+		next := fmt.Sprintf("%s%s/?offset=%d&limit=%d", baseURL, endpoint, offset+limit, limit)
+		page.Next = &next
+	}
+	if offset > 0 {
+		previous := fmt.Sprintf("%s%s/?offset=%d&limit=%d", baseURL, endpoint, max(0, offset-limit), limit)
+		page.Previous = &previous
+	}
+
+	body, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, body)
+}
+
+// queryInt reads a non-negative integer query parameter, falling back to def
+// if it is missing or malformed.
+func queryInt(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// baseURLFor returns the root of the API as the client sees it, so links
+// lead back to this server.
+func baseURLFor(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + Prefix
+}
+
+func writeJSON(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(body)
+}
+
+// Server is a fake PokeAPI running on a local port, for tests.
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer starts a Server. Callers should Close it when done.
+func NewServer() *Server {
+	return &Server{Server: httptest.NewServer(NewHandler())}
+}
+
+// BaseURL returns the root of the API, to hand to pokeapi.NewClient.
+func (s *Server) BaseURL() string {
+	return s.URL + Prefix
+}
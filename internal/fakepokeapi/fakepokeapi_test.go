@@ -0,0 +1,111 @@
+package fakepokeapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func getJSON(t *testing.T, url string, v any) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && v != nil {
+		err = json.NewDecoder(resp.Body).Decode(v)
+		if err != nil {
+			t.Fatalf("unable to decode %s: %v", url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestPagination(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	var first listPage
+	getJSON(t, server.BaseURL()+"location-area/", &first)
+	if len(first.Results) != defaultLimit || first.Count <= defaultLimit {
+		t.Fatalf("expected a full first page of %d, got %d of %d", defaultLimit, len(first.Results), first.Count)
+	}
+	if first.Previous != nil {
+		t.Errorf("expected no previous page, got %s", *first.Previous)
+	}
+	if first.Next == nil || *first.Next != server.BaseURL()+"location-area/?offset=20&limit=20" {
+		t.Fatalf("unexpected next page: %v", first.Next)
+	}
+
+	var second listPage
+	getJSON(t, *first.Next, &second)
+	if len(second.Results) != first.Count-defaultLimit {
+		t.Errorf("expected the rest of the areas, got %d", len(second.Results))
+	}
+	if second.Next != nil {
+		t.Errorf("expected no next page, got %s", *second.Next)
+	}
+	if second.Previous == nil || *second.Previous != server.BaseURL()+"location-area/?offset=0&limit=20" {
+		t.Errorf("unexpected previous page: %v", second.Previous)
+	}
+}
+
+func TestResourceLookup(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	for _, name := range []string{"pikachu", "25"} {
+		var pokemon struct {
+			ID      int    `json:"id"`
+			Name    string `json:"name"`
+			Species struct {
+				URL string `json:"url"`
+			} `json:"species"`
+		}
+		status := getJSON(t, server.BaseURL()+"pokemon/"+name+"/", &pokemon)
+		if status != http.StatusOK || pokemon.ID != 25 || pokemon.Name != "pikachu" {
+			t.Errorf("unexpected pokemon for %s: %d %+v", name, status, pokemon)
+		}
+		// Links lead back to this server rather than the real API
+		if !strings.HasPrefix(pokemon.Species.URL, server.BaseURL()) {
+			t.Errorf("expected links to point at the fake server, got %s", pokemon.Species.URL)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	for _, endpoint := range []string{"location-area", "pokemon", "pokemon-species", "type", "move"} {
+		var page listPage
+		status := getJSON(t, server.BaseURL()+endpoint+"/?limit=1", &page)
+		if status != http.StatusOK || len(page.Results) != 1 {
+			t.Errorf("expected %s to be served, got %d", endpoint, status)
+			continue
+		}
+		var res struct {
+			Name string `json:"name"`
+		}
+		getJSON(t, page.Results[0].URL, &res)
+		if res.Name != page.Results[0].Name {
+			t.Errorf("expected %s to be fetchable by its list URL", page.Results[0].Name)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	for _, path := range []string{"pokemon/missingno/", "berry/", "pokemon/pikachu/extra/"} {
+		if status := getJSON(t, server.BaseURL()+path, nil); status != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", path, status)
+		}
+	}
+	if status := getJSON(t, server.URL+"/pokemon/", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 outside %s, got %d", Prefix, status)
+	}
+}
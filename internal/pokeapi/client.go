@@ -0,0 +1,385 @@
+package pokeapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+)
+
+// DefaultBaseURL is the public PokeAPI endpoint used when nothing else is configured.
+const DefaultBaseURL = "https://pokeapi.co/api/v2/"
+
+// Client talks to a PokeAPI instance, serving repeat requests from its cache.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	cache      *pokecache.Cache
+	timeout    time.Duration
+	// maxAttempts is how many times a request is tried before giving up.
+	maxAttempts int
+	logger      *log.Logger
+	// rateLimit is the sustained requests per second allowed; zero is unlimited.
+	rateLimit      float64
+	rateBurst      int
+	maxConcurrency int
+	flights        flightGroup
+	// refreshes holds a slot for each background refresh running, so at
+	// most maxConcurrency are in flight at once.
+	refreshes chan struct{}
+
+	// listTTL and resourceTTL are how long list pages and individual
+	// resources stay cached; zero uses the cache's default.
+	listTTL              time.Duration
+	resourceTTL          time.Duration
+	staleWhileRevalidate bool
+	// offline is toggled from the REPL while requests may be in flight.
+	offline atomic.Bool
+
+	// Decoded views of the cache, so repeat lookups skip json.Unmarshal
+	lists   *pokecache.TypedCache[NamedAPIResourceList]
+	areas   *pokecache.TypedCache[LocationArea]
+	pokemon *pokecache.TypedCache[Pokemon]
+}
+
+// Option configures optional Client behaviour in NewClient.
+type Option func(*Client)
+
+// WithHTTPClient makes the Client send its requests through httpClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds how long any single request may take. Zero means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithMaxAttempts retries failed requests until maxAttempts tries have been
+// made. Values below 2 disable retries.
+func WithMaxAttempts(maxAttempts int) Option {
+	return func(c *Client) {
+		c.maxAttempts = maxAttempts
+	}
+}
+
+// WithRateLimit caps all requests made by the Client, including retries, at
+// perSecond on average with bursts of up to burst. Zero disables the limit.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(c *Client) {
+		c.rateLimit = perSecond
+		c.rateBurst = burst
+	}
+}
+
+// WithMaxConcurrency sets how many requests bulk operations keep in flight.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) {
+		c.maxConcurrency = n
+	}
+}
+
+// WithCacheTTLs sets how long list pages and individual resources, such as a
+// pokemon, are cached. Resources rarely change so they can be kept far longer.
+// Zero uses the cache's default interval.
+func WithCacheTTLs(listTTL, resourceTTL time.Duration) Option {
+	return func(c *Client) {
+		c.listTTL = listTTL
+		c.resourceTTL = resourceTTL
+	}
+}
+
+// WithStaleWhileRevalidate answers from expired cache entries, when the cache
+// still holds them, while refreshing them in the background, at most
+// MaxConcurrency at a time.
+func WithStaleWhileRevalidate(enabled bool) Option {
+	return func(c *Client) {
+		c.staleWhileRevalidate = enabled
+	}
+}
+
+// WithLogger sends debug output, such as retries, to logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient returns a Client for the PokeAPI rooted at baseURL.
+// An empty baseURL falls back to DefaultBaseURL.
+func NewClient(baseURL string, cache *pokecache.Cache, opts ...Option) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	client := Client{
+		httpClient: http.DefaultClient,
+		baseURL:    baseURL,
+		cache:      cache,
+		logger:     log.New(io.Discard, "", 0),
+		// Stay polite by default if nothing else is configured
+		maxConcurrency: 8,
+	}
+	for _, opt := range opts {
+		opt(&client)
+	}
+	client.lists = pokecache.NewTypedCache(cache, decodeJSON[NamedAPIResourceList])
+	client.areas = pokecache.NewTypedCache(cache, decodeJSON[LocationArea])
+	client.pokemon = pokecache.NewTypedCache(cache, decodeJSON[Pokemon])
+	if client.maxConcurrency < 1 {
+		client.maxConcurrency = 1
+	}
+	client.refreshes = make(chan struct{}, client.maxConcurrency)
+
+	// Copy the http.Client so we don't modify one the caller shares elsewhere
+	wrapped := *client.httpClient
+	if wrapped.Transport == nil {
+		wrapped.Transport = http.DefaultTransport
+	}
+	if client.rateLimit > 0 {
+		wrapped.Transport = &limitedTransport{base: wrapped.Transport, limiter: newRateLimiter(client.rateLimit, client.rateBurst)}
+	}
+	if client.maxAttempts > 1 {
+		wrapped.Transport = newRetryTransport(wrapped.Transport, client.maxAttempts, client.logger)
+	}
+	client.httpClient = &wrapped
+	return &client
+}
+
+// BaseURL returns the root URL every request is made against.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// MaxConcurrency returns how many requests bulk operations may keep in flight.
+func (c *Client) MaxConcurrency() int {
+	return c.maxConcurrency
+}
+
+// FlushCache writes out the cache's disk index, so that what has been
+// fetched so far is still cached after a restart.
+func (c *Client) FlushCache() error {
+	return c.cache.Flush()
+}
+
+// locationAreaPageSize is the API's default page size, which map pages by.
+const locationAreaPageSize = 20
+
+// LocationAreasURL returns the URL of the first page of location areas. It
+// spells out the offset and limit just as the API's own previous links do,
+// so paging back to the first page finds it under the same cache key.
+func (c *Client) LocationAreasURL() string {
+	return fmt.Sprintf("%slocation-area/?offset=0&limit=%d", c.baseURL, locationAreaPageSize)
+}
+
+// ListLocationAreas fetches one page of location areas. An empty pageURL
+// fetches the first page.
+func (c *Client) ListLocationAreas(ctx context.Context, pageURL string) (NamedAPIResourceList, error) {
+	if pageURL == "" {
+		pageURL = c.LocationAreasURL()
+	}
+	return get(ctx, c, c.lists, pageURL, c.listTTL)
+}
+
+// PokemonURL returns the URL of the first page of pokemon.
+func (c *Client) PokemonURL() string {
+	return c.baseURL + "pokemon/"
+}
+
+// ListPokemon fetches one page of pokemon. An empty pageURL fetches the
+// first page.
+func (c *Client) ListPokemon(ctx context.Context, pageURL string) (NamedAPIResourceList, error) {
+	if pageURL == "" {
+		pageURL = c.PokemonURL()
+	}
+	return get(ctx, c, c.lists, pageURL, c.listTTL)
+}
+
+// GetLocationArea fetches a single location area by name or ID.
+func (c *Client) GetLocationArea(ctx context.Context, name string) (LocationArea, error) {
+	return get(ctx, c, c.areas, c.baseURL+"location-area/"+name+"/", c.resourceTTL)
+}
+
+// GetPokemon fetches a single pokemon by name or ID.
+func (c *Client) GetPokemon(ctx context.Context, name string) (Pokemon, error) {
+	return get(ctx, c, c.pokemon, c.baseURL+"pokemon/"+name+"/", c.resourceTTL)
+}
+
+// get returns the resource at url decoded as T, using the typed cache when it
+// can. Fetched bodies are cached for ttl, where zero means the cache's default.
+func get[T any](ctx context.Context, c *Client, typed *pokecache.TypedCache[T], url string, ttl time.Duration) (T, error) {
+	if c.Offline() {
+		// Expired data, however old, beats none when we can't fetch a replacement
+		val, _, found := typed.GetAnyAge(url)
+		if !found {
+			return val, fmt.Errorf("error fetching %s: %w", url, ErrOffline)
+		}
+		return val, nil
+	}
+
+	// If URL is in Cache, skip Fetch
+	val, fresh, found := typed.GetStale(url)
+	switch {
+	case found && fresh:
+		return val, nil
+	case found && c.staleWhileRevalidate:
+		// Answer with what we have and refresh it for next time
+		c.startRefresh(url, ttl)
+		return val, nil
+	}
+
+	body, err := c.load(ctx, url, ttl)
+	if err != nil {
+		return val, err
+	}
+	val, err = decodeJSON[T](body)
+	if err != nil {
+		// Don't keep serving a bad response; the next call fetches it again
+		c.cache.Remove(url)
+		return val, &DecodeError{URL: url, Err: err}
+	}
+	typed.Attach(url, val)
+	return val, nil
+}
+
+func decodeJSON[T any](body []byte) (T, error) {
+	var val T
+	err := json.Unmarshal(body, &val)
+	return val, err
+}
+
+// load fetches url and caches the body for ttl. If the cache still holds an
+// expired copy with validators, the server is asked whether it has changed
+// and a 304 reply just refreshes the cached copy.
+func (c *Client) load(ctx context.Context, url string, ttl time.Duration) ([]byte, error) {
+	// Concurrent misses for the same URL share a single request. It runs on
+	// its own context, bounded by the client's timeout, so one caller giving
+	// up doesn't fail it for the others.
+	return c.flights.do(ctx, url, func(ctx context.Context) ([]byte, error) {
+		// Someone may have filled the cache since we last looked. The caller
+		// has already counted this lookup, so don't count it again.
+		cached, fresh, _ := c.cache.Peek(url)
+		if fresh {
+			return cached, nil
+		}
+
+		validators, _ := c.cache.GetValidators(url)
+		resp, err := c.fetch(ctx, url, validators)
+		if err != nil {
+			return nil, err
+		}
+		if resp.notModified {
+			// Refresh first: the cached copy may be past its stale window,
+			// and so only be found once it's fresh again
+			if c.cache.Refresh(url) {
+				cached, _, found := c.cache.Peek(url)
+				if found {
+					c.logger.Printf("revalidated %s", url)
+					return cached, nil
+				}
+			}
+			// The cached copy went away while we were asking; fetch it in full
+			resp, err = c.fetch(ctx, url, pokecache.Validators{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		c.cache.AddWithValidators(url, resp.body, ttl, resp.validators)
+		return resp.body, nil
+	})
+}
+
+// startRefresh refreshes a stale url in the background, unless
+// MaxConcurrency refreshes are already running. Then it is left stale for a
+// later lookup to refresh, so a page of stale areas can't put more requests
+// in flight than the bulk operations that found them.
+func (c *Client) startRefresh(url string, ttl time.Duration) {
+	select {
+	case c.refreshes <- struct{}{}:
+	default:
+		c.logger.Printf("skipped background refresh of %s: too many running", url)
+		return
+	}
+	go func() {
+		defer func() { <-c.refreshes }()
+		c.refresh(url, ttl)
+	}()
+}
+
+// refresh reloads a stale url in the background. The command that found it
+// stale has already moved on, so failures only go to the debug log.
+func (c *Client) refresh(url string, ttl time.Duration) {
+	_, err := c.load(context.Background(), url, ttl)
+	if err != nil {
+		c.logger.Printf("background refresh of %s failed: %v", url, err)
+	}
+}
+
+// fetchResponse is the outcome of a successful fetch.
+type fetchResponse struct {
+	body       []byte
+	validators pokecache.Validators
+	// notModified is set when a conditional request got a 304 and body is empty.
+	notModified bool
+}
+
+// fetch returns the body at url straight from the network. If validators are
+// given the request is made conditional on the resource having changed.
+func (c *Client) fetch(ctx context.Context, url string, validators pokecache.Validators) (fetchResponse, error) {
+	if c.Offline() {
+		return fetchResponse{}, fmt.Errorf("error fetching %s: %w", url, ErrOffline)
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fetchResponse{}, fmt.Errorf("error creating HTTP request for %s: %w", url, err)
+	}
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fetchResponse{}, fmt.Errorf("error making HTTP request for %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && !validators.IsZero() {
+		return fetchResponse{notModified: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fetchResponse{}, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fetchResponse{}, fmt.Errorf("error reading response body for %s: %w", url, err)
+	}
+	return fetchResponse{
+		body: body,
+		validators: pokecache.Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, nil
+}
@@ -0,0 +1,390 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+)
+
+// newTestCache returns a cache that is closed when the test finishes.
+func newTestCache(t *testing.T) *pokecache.Cache {
+	cache := pokecache.NewCache(time.Minute)
+	t.Cleanup(cache.Close)
+	return cache
+}
+
+// newFakeClockCache returns a cache that keeps expired entries for an hour,
+// and the clock it tells time with, so tests can expire entries by advancing it.
+func newFakeClockCache(t *testing.T) (*pokecache.Cache, *pokecache.FakeClock) {
+	clock := pokecache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := pokecache.NewCache(time.Minute, pokecache.WithStaleTTL(time.Hour), pokecache.WithClock(clock))
+	t.Cleanup(cache.Close)
+	return cache, clock
+}
+
+// newTestServer serves body for every request and counts how many it received.
+func newTestServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestGetPokemon(t *testing.T) {
+	server, hits := newTestServer(t, http.StatusOK, `{"id": 25, "name": "pikachu", "base_experience": 112}`)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	for i := 0; i < 2; i++ {
+		pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pokemon.Name != "pikachu" || pokemon.BaseExperience != 112 {
+			t.Errorf("unexpected pokemon: %+v", pokemon)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected second call to be served from cache, got %d requests", hits.Load())
+	}
+}
+
+func TestCacheStatsCountEachLookupOnce(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusOK, `{"id": 25, "name": "pikachu"}`)
+	cache := newTestCache(t)
+	client := NewClient(server.URL, cache, WithHTTPClient(server.Client()))
+
+	// One fetch from the network, then one from the cache
+	for i := 0; i < 2; i++ {
+		_, err := client.GetPokemon(context.Background(), "pikachu")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+func TestListLocationAreas(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusOK, `{"count": 1, "next": null, "previous": null, "results": [{"name": "canalave-city-area", "url": "x"}]}`)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	list, err := client.ListLocationAreas(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Results) != 1 || list.Results[0].Name != "canalave-city-area" {
+		t.Errorf("unexpected list: %+v", list)
+	}
+	if list.Next != nil {
+		t.Errorf("expected no next page")
+	}
+}
+
+func TestNonOKStatus(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{status: http.StatusNotFound, want: ErrNotFound},
+		{status: http.StatusTooManyRequests, want: ErrRateLimited},
+		{status: http.StatusServiceUnavailable, want: nil},
+	}
+	for _, c := range cases {
+		t.Run(http.StatusText(c.status), func(t *testing.T) {
+			server, _ := newTestServer(t, c.status, http.StatusText(c.status))
+			client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+			_, err := client.GetLocationArea(context.Background(), "nowhere")
+			var statusErr *HTTPStatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != c.status {
+				t.Fatalf("expected an HTTPStatusError for %d, got %v", c.status, err)
+			}
+			for _, kind := range []error{ErrNotFound, ErrRateLimited} {
+				if errors.Is(err, kind) != (kind == c.want) {
+					t.Errorf("unexpected match of %v against %v", err, kind)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusOK, `{"id": 25, "name": `)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !strings.HasSuffix(decodeErr.URL, "/pokemon/pikachu/") {
+		t.Errorf("expected a DecodeError, got %v", err)
+	}
+}
+
+func TestDecodeErrorIsNotCached(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first response is cut short, the next one is fine
+		if hits.Add(1) == 1 {
+			w.Write([]byte(`{"id": 25, "name": `))
+			return
+		}
+		w.Write([]byte(`{"id": 25, "name": "pikachu"}`))
+	}))
+	t.Cleanup(server.Close)
+	cache := pokecache.NewCache(time.Minute, pokecache.WithDiskDir(t.TempDir()))
+	t.Cleanup(cache.Close)
+	client := NewClient(server.URL, cache, WithHTTPClient(server.Client()))
+
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected a DecodeError, got %v", err)
+	}
+	if _, _, found := cache.Peek(client.BaseURL() + "pokemon/pikachu/"); found {
+		t.Errorf("expected the bad response to be dropped from memory and disk")
+	}
+
+	pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.Name != "pikachu" {
+		t.Errorf("expected to recover with a fresh fetch, got %+v, %v", pokemon, err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", hits.Load())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()), WithTimeout(10*time.Millisecond))
+
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := client.GetPokemon(ctx, "pikachu")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server, hits := newTestServer(t, http.StatusOK, `{}`)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()), WithRateLimit(50, 1))
+
+	start := time.Now()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		_, err := client.GetPokemon(context.Background(), name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// The first request spends the burst; the other four wait 20ms each
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("expected requests to be rate limited, took %s", elapsed)
+	}
+	if hits.Load() != 5 {
+		t.Errorf("expected 5 requests, got %d", hits.Load())
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id": %d, "name": "pikachu"}`, version.Add(1))
+	}))
+	t.Cleanup(server.Close)
+	cache, clock := newFakeClockCache(t)
+	client := NewClient(server.URL, cache,
+		WithHTTPClient(server.Client()),
+		WithCacheTTLs(0, time.Minute),
+		WithStaleWhileRevalidate(true),
+	)
+
+	pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.ID != 1 {
+		t.Fatalf("unexpected first fetch: %+v, %v", pokemon, err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	// The entry has expired, so we should get the stale copy straight away...
+	pokemon, err = client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.ID != 1 {
+		t.Fatalf("expected the stale value, got %+v, %v", pokemon, err)
+	}
+
+	// ...and the refreshed one once the background fetch lands
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		body, _, _ := cache.Peek(client.BaseURL() + "pokemon/pikachu/")
+		if strings.Contains(string(body), `"id": 2`) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("expected the stale entry to be refreshed in the background")
+}
+
+func TestStaleRefreshesAreBounded(t *testing.T) {
+	var blocking atomic.Bool
+	var hits, inFlight, peak atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if blocking.Load() {
+			recordPeak(&peak, inFlight.Add(1))
+			<-release
+			inFlight.Add(-1)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	cache, clock := newFakeClockCache(t)
+	client := NewClient(server.URL, cache,
+		WithHTTPClient(server.Client()),
+		WithCacheTTLs(0, time.Minute),
+		WithStaleWhileRevalidate(true),
+		WithMaxConcurrency(2),
+	)
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		_, err := client.GetPokemon(context.Background(), name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	clock.Advance(2 * time.Minute)
+
+	// Every lookup is answered from the cache while the refreshes hang
+	blocking.Store(true)
+	for _, name := range names {
+		_, err := client.GetPokemon(context.Background(), name)
+		if err != nil {
+			t.Fatalf("expected the stale value, got %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for inFlight.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// Give any refresh over the limit the chance to show up
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if peak.Load() != 2 {
+		t.Errorf("expected 2 background refreshes in flight at most, got %d", peak.Load())
+	}
+	if got := hits.Load(); got != int32(len(names))+2 {
+		t.Errorf("expected the refreshes over the limit to be skipped, got %d requests", got)
+	}
+}
+
+// recordPeak raises peak to n if n is higher.
+func recordPeak(peak *atomic.Int32, n int32) {
+	for {
+		old := peak.Load()
+		if n <= old || peak.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+func TestConditionalRevalidation(t *testing.T) {
+	var full, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id": 25, "name": "pikachu"}`))
+	}))
+	t.Cleanup(server.Close)
+	cache, clock := newFakeClockCache(t)
+	client := NewClient(server.URL, cache, WithHTTPClient(server.Client()), WithCacheTTLs(0, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+		if err != nil || pokemon.Name != "pikachu" {
+			t.Fatalf("unexpected fetch: %+v, %v", pokemon, err)
+		}
+		// Let the entry expire so the next call has to revalidate
+		clock.Advance(2 * time.Minute)
+	}
+
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("expected 1 full fetch and 1 revalidation, got %d and %d", full.Load(), notModified.Load())
+	}
+	// Neither call found a fresh value, and each counts once
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 2 {
+		t.Errorf("expected 2 misses, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+}
+
+func TestConditionalRevalidationFromDisk(t *testing.T) {
+	var full, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id": 25, "name": "pikachu"}`))
+	}))
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+	clock := pokecache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newClient := func() *Client {
+		cache := pokecache.NewCache(time.Minute, pokecache.WithDiskDir(dir), pokecache.WithStaleTTL(time.Hour), pokecache.WithClock(clock))
+		t.Cleanup(cache.Close)
+		return NewClient(server.URL, cache, WithHTTPClient(server.Client()), WithCacheTTLs(0, time.Minute))
+	}
+
+	client := newClient()
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.FlushCache()
+
+	// Past the stale window, the entry is only left on disk
+	clock.Advance(2 * time.Hour)
+	client = newClient()
+	pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.Name != "pikachu" {
+		t.Fatalf("unexpected fetch: %+v, %v", pokemon, err)
+	}
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("expected 1 full fetch and 1 revalidation, got %d and %d", full.Load(), notModified.Load())
+	}
+}
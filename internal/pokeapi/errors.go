@@ -0,0 +1,55 @@
+package pokeapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is matched by errors for resources the API doesn't have.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is matched by errors for requests the API turned away
+	// for being too frequent, even after retrying.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrOffline is returned when the Client is offline and what was asked
+	// for isn't in the cache.
+	ErrOffline = errors.New("offline and not cached")
+)
+
+// HTTPStatusError is returned when the API answers with a status other than
+// 200 OK. A 404 matches ErrNotFound and a 429 matches ErrRateLimited.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	// Status is the full status line, such as "404 Not Found".
+	Status string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("received non-OK HTTP status for %s: %s", e.URL, e.Status)
+}
+
+func (e *HTTPStatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// DecodeError is returned when a response body can't be parsed.
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error unmarshaling JSON for %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
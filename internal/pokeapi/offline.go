@@ -0,0 +1,20 @@
+package pokeapi
+
+// WithOffline starts the Client in offline mode, see SetOffline.
+func WithOffline(offline bool) Option {
+	return func(c *Client) {
+		c.offline.Store(offline)
+	}
+}
+
+// SetOffline switches offline mode on or off. While offline the Client never
+// touches the network: it answers from the cache, even with expired entries
+// it still holds, and returns ErrOffline for anything else.
+func (c *Client) SetOffline(offline bool) {
+	c.offline.Store(offline)
+}
+
+// Offline reports whether the Client is in offline mode.
+func (c *Client) Offline() bool {
+	return c.offline.Load()
+}
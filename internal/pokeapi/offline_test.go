@@ -0,0 +1,59 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOfflineServesCache(t *testing.T) {
+	server, hits := newTestServer(t, http.StatusOK, `{"id": 25, "name": "pikachu"}`)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client.SetOffline(true)
+
+	pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.Name != "pikachu" {
+		t.Errorf("expected the cached pokemon, got %+v, %v", pokemon, err)
+	}
+	_, err = client.GetPokemon(context.Background(), "eevee")
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("expected ErrOffline for an uncached pokemon, got %v", err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected no requests while offline, got %d", hits.Load()-1)
+	}
+
+	client.SetOffline(false)
+	_, err = client.GetPokemon(context.Background(), "eevee")
+	if err != nil {
+		t.Errorf("expected to fetch again once back online, got %v", err)
+	}
+}
+
+func TestOfflineServesExpiredEntries(t *testing.T) {
+	server, hits := newTestServer(t, http.StatusOK, `{"id": 25, "name": "pikachu"}`)
+	cache, clock := newFakeClockCache(t)
+	client := NewClient(server.URL, cache, WithHTTPClient(server.Client()), WithCacheTTLs(0, time.Minute))
+
+	_, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	client.SetOffline(true)
+	pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+	if err != nil || pokemon.Name != "pikachu" {
+		t.Errorf("expected the expired pokemon, got %+v, %v", pokemon, err)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected no revalidation while offline, got %d requests", hits.Load())
+	}
+}
@@ -0,0 +1,103 @@
+package pokeapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryTransport retries requests that fail with a network error, a 429 or a
+// 5xx, waiting a jittered exponential backoff between attempts. A Retry-After
+// header from the server takes precedence over the computed backoff.
+type retryTransport struct {
+	base        http.RoundTripper
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	logger      *log.Logger
+}
+
+func newRetryTransport(base http.RoundTripper, maxAttempts int, logger *log.Logger) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{
+		base:        base,
+		maxAttempts: maxAttempts,
+		baseDelay:   250 * time.Millisecond,
+		maxDelay:    10 * time.Second,
+		logger:      logger,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		// Only bodyless requests can be replayed safely
+		if attempt >= t.maxAttempts || req.Body != nil || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = min(retryAfter, t.maxDelay)
+			}
+			t.logger.Printf("retrying %s after %s (attempt %d/%d): %s", req.URL, delay, attempt, t.maxAttempts, resp.Status)
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			t.logger.Printf("retrying %s after %s (attempt %d/%d): %v", req.URL, delay, attempt, t.maxAttempts, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a random delay up to baseDelay*2^(attempt-1), capped at maxDelay.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.baseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.maxDelay {
+		ceiling = t.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		// A cancelled or timed out request won't do better next time
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// parseRetryAfter understands both forms of Retry-After: delay seconds and an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(date), 0), true
+}
@@ -0,0 +1,117 @@
+package pokeapi
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with status, then succeeds.
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func newTestRetryClient(server *httptest.Server, maxAttempts int) *http.Client {
+	transport := newRetryTransport(server.Client().Transport, maxAttempts, log.New(io.Discard, "", 0))
+	transport.baseDelay = time.Millisecond
+	return &http.Client{Transport: transport}
+}
+
+func TestRetryRecovers(t *testing.T) {
+	server, hits := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	client := newTestRetryClient(server, 3)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected success after retries, got %s", resp.Status)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", hits.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server, hits := flakyServer(t, 10, http.StatusInternalServerError, nil)
+	client := newTestRetryClient(server, 3)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected last failure to be returned, got %s", resp.Status)
+	}
+	if hits.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", hits.Load())
+	}
+}
+
+func TestRetryNotOnClientError(t *testing.T) {
+	server, hits := flakyServer(t, 10, http.StatusNotFound, nil)
+	client := newTestRetryClient(server, 3)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if hits.Load() != 1 {
+		t.Errorf("expected a 404 not to be retried, got %d attempts", hits.Load())
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	server, _ := flakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}})
+	client := newTestRetryClient(server, 2)
+
+	// The one second Retry-After must outlast this deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+	if err == nil {
+		t.Errorf("expected the Retry-After wait to exceed the deadline")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{value: "", ok: false},
+		{value: "3", want: 3 * time.Second, ok: true},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+		{value: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0, ok: true},
+	}
+	for _, c := range cases {
+		got, ok := parseRetryAfter(c.value)
+		if ok != c.ok || got != c.want {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", c.value, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package pokeapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// flightGroup coalesces concurrent fetches of the same key so only one is in
+// flight at a time; everyone waiting on it receives the same result.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	val  []byte
+	err  error
+	// waiting counts the callers still waiting on the result, and cancel
+	// stops the call once none are left.
+	waiting int
+	cancel  context.CancelFunc
+}
+
+// do runs fn for key unless a call for key is already running, in which case
+// it waits for that call instead. fn isn't cancelled with ctx, since other
+// callers may still want its result; each caller stops waiting when its own
+// ctx is done, and fn's context is cancelled once every caller has stopped.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, exists := g.flights[key]
+	if !exists {
+		var flightCtx context.Context
+		f = &flight{done: make(chan struct{})}
+		flightCtx, f.cancel = context.WithCancel(context.WithoutCancel(ctx))
+		g.flights[key] = f
+		go g.run(flightCtx, key, f, fn)
+	}
+	f.waiting++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, ctx.Err()
+	}
+}
+
+// run calls fn and hands its result to everyone waiting on f. It runs in a
+// goroutine of its own, so a panic in fn is handed over as an error rather
+// than crashing the program.
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fn func(context.Context) ([]byte, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.val, f.err = nil, fmt.Errorf("error fetching %s: panic: %v", key, r)
+		}
+		g.mu.Lock()
+		g.forget(key, f)
+		g.mu.Unlock()
+		f.cancel()
+		close(f.done)
+	}()
+	f.val, f.err = fn(ctx)
+}
+
+// leave records that a caller has stopped waiting on f, cancelling it if it
+// was the last. Later callers then start a fresh call rather than joining
+// one that is being abandoned.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f.waiting--
+	if f.waiting == 0 {
+		g.forget(key, f)
+		f.cancel()
+	}
+}
+
+// forget removes f from the group if it's still the call for key. g.mu must be held.
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
@@ -0,0 +1,162 @@
+package pokeapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// waiters returns how many callers are waiting on the call for key.
+func (g *flightGroup) waiters(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f, exists := g.flights[key]
+	if !exists {
+		return 0
+	}
+	return f.waiting
+}
+
+// waitForCallers blocks until n callers are waiting on the call for key.
+func waitForCallers(g *flightGroup, key string, n int) {
+	for g.waiters(key) < n {
+		runtime.Gosched()
+	}
+}
+
+func TestConcurrentRequestsCoalesce(t *testing.T) {
+	const callers = 20
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// Hold the response until every caller is waiting on it
+		<-release
+		w.Write([]byte(`{"id": 25, "name": "pikachu"}`))
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, newTestCache(t), WithHTTPClient(server.Client()))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pokemon, err := client.GetPokemon(context.Background(), "pikachu")
+			if err == nil && pokemon.Name != "pikachu" {
+				t.Errorf("unexpected pokemon: %+v", pokemon)
+			}
+			errs <- err
+		}()
+	}
+	waitForCallers(&client.flights, client.baseURL+"pokemon/pikachu/", callers)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected a single upstream request, got %d", hits.Load())
+	}
+}
+
+func TestFlightGroupSharesErrors(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := g.do(context.Background(), "key", func(context.Context) ([]byte, error) {
+				calls.Add(1)
+				<-release
+				return nil, context.DeadlineExceeded
+			})
+			results <- err
+		}()
+	}
+	waitForCallers(&g, "key", 2)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected shared error, got %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected fn to run once, got %d", calls.Load())
+	}
+}
+
+func TestFlightGroupOutlivesCancelledCaller(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	fn := func(ctx context.Context) ([]byte, error) {
+		<-release
+		// The shared call must not see the first caller's cancellation
+		return []byte("pikachu"), ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		first <- err
+	}()
+	waitForCallers(&g, "key", 1)
+	second := make(chan []byte, 1)
+	go func() {
+		val, err := g.do(context.Background(), "key", fn)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		second <- val
+	}()
+	waitForCallers(&g, "key", 2)
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("expected the cancelled caller to give up, got %v", err)
+	}
+	close(release)
+	if val := <-second; string(val) != "pikachu" {
+		t.Errorf("expected the other caller to get the result, got %q", val)
+	}
+}
+
+func TestFlightGroupPanicReleasesKey(t *testing.T) {
+	var g flightGroup
+	_, err := g.do(context.Background(), "key", func(context.Context) ([]byte, error) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic to be returned as an error, got %v", err)
+	}
+	if g.waiters("key") != 0 {
+		t.Errorf("expected the key to be released")
+	}
+
+	// The next call for the key runs afresh
+	val, err := g.do(context.Background(), "key", func(context.Context) ([]byte, error) {
+		return []byte("pikachu"), nil
+	})
+	if err != nil || string(val) != "pikachu" {
+		t.Errorf("expected a fresh call, got %q, %v", val, err)
+	}
+}
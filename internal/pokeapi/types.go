@@ -0,0 +1,144 @@
+package pokeapi
+
+// LocationArea represents the structure of a single location area from PokeAPI.
+// JSON tags (`json:"name"`) are used to map JSON keys to Go struct fields.
+type LocationArea struct {
+	ID                   int                   `json:"id"`
+	Name                 string                `json:"name"`
+	GameIndex            int                   `json:"game_index"`
+	EncounterMethodRates []EncounterMethodRate `json:"encounter_method_rates"`
+	Location             NamedAPIResource      `json:"location"`
+	Names                []Name                `json:"names"`
+	PokemonEncounters    []PokemonEncounter    `json:"pokemon_encounters"`
+}
+
+type EncounterMethodRate struct {
+	EncounterMethod NamedAPIResource `json:"encounter_method"`
+	VersionDetails  []VersionDetail  `json:"version_details"`
+}
+
+type VersionDetail struct {
+	Rate    int              `json:"rate"`
+	Version NamedAPIResource `json:"version"`
+}
+
+type PokemonEncounter struct {
+	Pokemon        NamedAPIResource         `json:"pokemon"`
+	VersionDetails []VersionEncounterDetail `json:"version_details"`
+}
+
+type VersionEncounterDetail struct {
+	EncounterDetails []EncounterDetail `json:"encounter_details"`
+	MaxChance        int               `json:"max_chance"`
+	Version          NamedAPIResource  `json:"version"`
+}
+
+type EncounterDetail struct {
+	Chance          int                `json:"chance"`
+	ConditionValues []NamedAPIResource `json:"condition_values"`
+	MaxLevel        int                `json:"max_level"`
+	Method          NamedAPIResource   `json:"method"`
+	MinLevel        int                `json:"min_level"`
+}
+
+type NamedAPIResource struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Name represents a localized name for a resource, typically found in 'names' arrays.
+type Name struct {
+	Name     string           `json:"name"`
+	Language NamedAPIResource `json:"language"`
+}
+
+type NamedAPIResourceList struct {
+	Count    int                `json:"count"`
+	Next     *string            `json:"next"`     // Pointer to string as it can be null
+	Previous *string            `json:"previous"` // Pointer to string as it can be null
+	Results  []NamedAPIResource `json:"results"`
+}
+
+// Pokemon represents the main structure of a single Pokemon from PokeAPI.
+type Pokemon struct {
+	ID             int                `json:"id"`
+	Name           string             `json:"name"`
+	BaseExperience int                `json:"base_experience"`
+	Height         int                `json:"height"`
+	IsDefault      bool               `json:"is_default"`
+	Order          int                `json:"order"`
+	Weight         int                `json:"weight"`
+	Abilities      []PokemonAbility   `json:"abilities"`
+	Forms          []NamedAPIResource `json:"forms"`
+	GameIndices    []VersionGameIndex `json:"game_indices"`
+	HeldItems      []PokemonHeldItem  `json:"held_items"`
+	Moves          []PokemonMove      `json:"moves"`
+	Species        NamedAPIResource   `json:"species"`
+	Sprites        PokemonSprites     `json:"sprites"`
+	Stats          []PokemonStat      `json:"stats"`
+	Types          []PokemonType      `json:"types"`
+}
+
+// PokemonAbility represents an ability of a Pokemon.
+type PokemonAbility struct {
+	IsHidden bool             `json:"is_hidden"`
+	Slot     int              `json:"slot"`
+	Ability  NamedAPIResource `json:"ability"`
+}
+
+// VersionGameIndex represents a game index for a Pokemon.
+type VersionGameIndex struct {
+	GameIndex int              `json:"game_index"`
+	Version   NamedAPIResource `json:"version"`
+}
+
+// PokemonHeldItem represents an item held by a Pokemon.
+type PokemonHeldItem struct {
+	Item           NamedAPIResource         `json:"item"`
+	VersionDetails []PokemonHeldItemVersion `json:"version_details"`
+}
+
+// PokemonHeldItemVersion represents version details for a held item.
+type PokemonHeldItemVersion struct {
+	Version NamedAPIResource `json:"version"`
+	Rarity  int              `json:"rarity"`
+}
+
+// PokemonMove represents a move a Pokemon can learn.
+type PokemonMove struct {
+	Move                NamedAPIResource     `json:"move"`
+	VersionGroupDetails []PokemonMoveVersion `json:"version_group_details"`
+}
+
+// PokemonMoveVersion represents version details for a Pokemon move.
+type PokemonMoveVersion struct {
+	LevelLearnedAt  int              `json:"level_learned_at"`
+	MoveLearnMethod NamedAPIResource `json:"move_learn_method"`
+	VersionGroup    NamedAPIResource `json:"version_group"`
+}
+
+// PokemonSprites contains URLs for various sprites of a Pokemon.
+type PokemonSprites struct {
+	BackDefault      *string `json:"back_default"`
+	BackFemale       *string `json:"back_female"`
+	BackShiny        *string `json:"back_shiny"`
+	BackShinyFemale  *string `json:"back_shiny_female"`
+	FrontDefault     *string `json:"front_default"`
+	FrontFemale      *string `json:"front_female"`
+	FrontShiny       *string `json:"front_shiny"`
+	FrontShinyFemale *string `json:"front_shiny_female"`
+	// Add other sprite fields if needed, e.g., "other", "versions"
+}
+
+// PokemonStat represents a stat of a Pokemon.
+type PokemonStat struct {
+	BaseStat int              `json:"base_stat"`
+	Effort   int              `json:"effort"`
+	Stat     NamedAPIResource `json:"stat"`
+}
+
+// PokemonType represents a type of a Pokemon.
+type PokemonType struct {
+	Slot int              `json:"slot"`
+	Type NamedAPIResource `json:"type"`
+}
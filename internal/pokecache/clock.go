@@ -0,0 +1,112 @@
+package pokecache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a Cache. Tests swap in a FakeClock so that
+// expiry can be checked by advancing virtual time instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the part of time.Ticker that Cache relies on.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock backed by the time package.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// FakeClock is a Clock that only moves when Advance is called.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock reading start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker panics if d isn't positive, as time.NewTicker does; Advance
+// could never get past such a ticker's next tick.
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ticker := &fakeTicker{clock: f, c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the clock forward by d, firing any tickers that come due.
+// Like a real ticker, a tick is dropped if the previous one hasn't been read.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, ticker := range f.tickers {
+		if ticker.stopped || ticker.next.After(f.now) {
+			continue
+		}
+		select {
+		case ticker.c <- f.now:
+		default:
+		}
+		// Skip past any ticks that fell inside d
+		for !ticker.next.After(f.now) {
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
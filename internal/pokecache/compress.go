@@ -0,0 +1,38 @@
+package pokecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+func compress(val []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(val)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(val []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(val))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// value returns the entry's value, decompressing it if needed.
+func (e *cacheEntry) value() ([]byte, error) {
+	if !e.compressed {
+		return e.val, nil
+	}
+	return decompress(e.val)
+}
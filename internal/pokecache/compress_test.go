@@ -0,0 +1,76 @@
+package pokecache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pokemonLikePayload builds a large, repetitive JSON body resembling a
+// PokeAPI pokemon response.
+func pokemonLikePayload() []byte {
+	var b strings.Builder
+	b.WriteString(`{"name": "pikachu", "moves": [`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"move": {"name": "move-%d", "url": "https://pokeapi.co/api/v2/move/%d/"}, "version_group_details": [{"level_learned_at": 0, "move_learn_method": {"name": "machine", "url": "https://pokeapi.co/api/v2/move-learn-method/4/"}}]}`, i, i)
+	}
+	b.WriteString(`]}`)
+	return []byte(b.String())
+}
+
+func TestCompression(t *testing.T) {
+	payload := pokemonLikePayload()
+	cache := NewCache(time.Minute, WithCompression(1024))
+	defer cache.Close()
+
+	cache.Add("big", payload)
+	cache.Add("small", []byte("testdata"))
+
+	val, ok := cache.Get("big")
+	if !ok || string(val) != string(payload) {
+		t.Errorf("expected to get the original value back")
+	}
+	val, ok = cache.Get("small")
+	if !ok || string(val) != "testdata" {
+		t.Errorf("expected to get the small value back")
+	}
+
+	stats := cache.Stats()
+	if stats.Bytes >= int64(len(payload)) {
+		t.Errorf("expected compression to save memory, using %d bytes for a %d byte value", stats.Bytes, len(payload))
+	}
+}
+
+func benchmarkCache(b *testing.B, opts ...Option) {
+	payload := pokemonLikePayload()
+	cache := NewCache(time.Minute, opts...)
+	defer cache.Close()
+
+	b.Run("Add", func(b *testing.B) {
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			cache.Add(fmt.Sprintf("key-%d", i%100), payload)
+		}
+		b.ReportMetric(float64(cache.Stats().Bytes)/float64(cache.Stats().Entries), "stored-bytes/entry")
+	})
+	b.Run("Get", func(b *testing.B) {
+		cache.Add("key", payload)
+		b.SetBytes(int64(len(payload)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cache.Get("key")
+		}
+	})
+}
+
+func BenchmarkUncompressed(b *testing.B) {
+	benchmarkCache(b)
+}
+
+func BenchmarkCompressed(b *testing.B) {
+	benchmarkCache(b, WithCompression(1024))
+}
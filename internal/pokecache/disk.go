@@ -0,0 +1,213 @@
+package pokecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/atomicfile"
+)
+
+const indexFile = "index.json"
+
+// diskStore keeps one file per cached key plus an index recording when each
+// key was created, so entries survive restarts and still expire on schedule.
+// Expiring only stops an entry being served as fresh: it stays on disk so it
+// can still be served while offline, until it's replaced, purged or older
+// than the cache's disk max age (see WithDiskMaxAge).
+// It has its own lock, so callers may hold a shard lock while using it but
+// must never take a shard lock from inside it.
+//
+// Rewriting the whole index on every change would make filling the cache
+// quadratic, so changes only mark it dirty and flush writes it out. Until
+// then a crash loses track of the newest files, which just costs a refetch.
+type diskStore struct {
+	mu    sync.Mutex
+	dir   string
+	index map[string]diskEntry
+	dirty bool
+}
+
+type diskEntry struct {
+	File      string    `json:"file"`
+	CreatedAt time.Time `json:"created_at"`
+	// TTL is zero for entries that use the cache's default interval.
+	TTL          time.Duration `json:"ttl,omitempty"`
+	ETag         string        `json:"etag,omitempty"`
+	LastModified string        `json:"last_modified,omitempty"`
+}
+
+func (e diskEntry) validators() Validators {
+	return Validators{ETag: e.ETag, LastModified: e.LastModified}
+}
+
+func openDiskStore(dir string) (*diskStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	store := &diskStore{dir: dir, index: make(map[string]diskEntry)}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		// A corrupt index only costs us the cached data, so start over.
+		if json.Unmarshal(data, &store.index) != nil {
+			store.index = make(map[string]diskEntry)
+		}
+	}
+	return store, nil
+}
+
+func (d *diskStore) get(key string) ([]byte, diskEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, exists := d.index[key]
+	if !exists {
+		return nil, diskEntry{}, false
+	}
+	val, err := os.ReadFile(filepath.Join(d.dir, entry.File))
+	if err != nil {
+		// The file has gone missing underneath us; forget about it.
+		delete(d.index, key)
+		d.dirty = true
+		return nil, diskEntry{}, false
+	}
+	return val, entry, true
+}
+
+func (d *diskStore) put(key string, val []byte, createdAt time.Time, ttl time.Duration, validators Validators) error {
+	name := diskFileName(key)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := atomicfile.Write(filepath.Join(d.dir, name), val)
+	if err != nil {
+		return err
+	}
+	d.index[key] = diskEntry{
+		File:         name,
+		CreatedAt:    createdAt,
+		TTL:          ttl,
+		ETag:         validators.ETag,
+		LastModified: validators.LastModified,
+	}
+	d.dirty = true
+	return nil
+}
+
+// diskFileName is the name of the file holding key's value.
+func diskFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// touch restarts an entry's lifetime without rewriting its file.
+func (d *diskStore) touch(key string, createdAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, exists := d.index[key]
+	if !exists {
+		return nil
+	}
+	entry.CreatedAt = createdAt
+	d.index[key] = entry
+	d.dirty = true
+	return nil
+}
+
+// entry returns the index entry for key without reading its file.
+func (d *diskStore) entry(key string) (diskEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, exists := d.index[key]
+	return entry, exists
+}
+
+func (d *diskStore) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.index)
+}
+
+// remove drops key, reporting whether it was stored.
+func (d *diskStore) remove(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry, exists := d.index[key]
+	if !exists {
+		return false
+	}
+	delete(d.index, key)
+	os.Remove(filepath.Join(d.dir, entry.File))
+	d.dirty = true
+	return true
+}
+
+// removePrefix drops every key starting with prefix, saving the index right
+// away so the files aren't still listed if we stop, and returns the keys it removed.
+func (d *diskStore) removePrefix(prefix string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var removed []string
+	for key, entry := range d.index {
+		if strings.HasPrefix(key, prefix) {
+			delete(d.index, key)
+			os.Remove(filepath.Join(d.dir, entry.File))
+			removed = append(removed, key)
+		}
+	}
+	if len(removed) > 0 {
+		d.saveIndex()
+	}
+	return removed
+}
+
+// removeOlderThan drops every entry created before cutoff and returns how
+// many it removed. Like other changes, it's only saved by the next flush.
+func (d *diskStore) removeOlderThan(cutoff time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := 0
+	for key, entry := range d.index {
+		if entry.CreatedAt.Before(cutoff) {
+			delete(d.index, key)
+			os.Remove(filepath.Join(d.dir, entry.File))
+			removed++
+		}
+	}
+	if removed > 0 {
+		d.dirty = true
+	}
+	return removed
+}
+
+// flush writes the index to disk if it has changed since it was last written.
+func (d *diskStore) flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.dirty {
+		return nil
+	}
+	return d.saveIndex()
+}
+
+// saveIndex writes the index to disk. d.mu must be held.
+func (d *diskStore) saveIndex() error {
+	data, err := json.Marshal(d.index)
+	if err != nil {
+		return err
+	}
+	err = atomicfile.Write(filepath.Join(d.dir, indexFile), data)
+	if err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
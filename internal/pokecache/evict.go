@@ -0,0 +1,98 @@
+package pokecache
+
+import "sync"
+
+// EvictReason says why an entry was removed from memory.
+type EvictReason int
+
+const (
+	// EvictExpired means the reaper dropped the entry for being past its
+	// TTL and stale window.
+	EvictExpired EvictReason = iota
+	// EvictSize means the entry was the least recently used when the cache
+	// needed room to stay within WithMaxBytes or WithMaxEntries.
+	EvictSize
+	// EvictPurge means the entry was removed by Purge, EvictPrefix or Remove.
+	EvictPurge
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictSize:
+		return "size"
+	case EvictPurge:
+		return "purge"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictFunc is called with each entry removed from memory. val is the value
+// as it was stored, decompressed, and must not be modified.
+type EvictFunc func(key string, val []byte, reason EvictReason)
+
+// evictHooks holds the functions registered with OnEvict.
+type evictHooks struct {
+	mu    sync.RWMutex
+	funcs []EvictFunc
+}
+
+// evicted is an entry removed under a shard lock whose hooks haven't run yet.
+type evicted struct {
+	key    string
+	entry  *cacheEntry
+	reason EvictReason
+}
+
+// OnEvict registers fn to be called whenever an entry leaves memory for
+// being expired, to make room, or because it was purged. Replacing an entry
+// with a new value doesn't count. Hooks run after the cache has released its
+// locks, so they may use the cache, but they hold up whichever call caused
+// the eviction and should be quick.
+func (c *Cache) OnEvict(fn EvictFunc) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.funcs = append(c.hooks.funcs, fn)
+}
+
+// evictLocked drops an entry from memory and queues it for the evict hooks,
+// which run once the shard is unlocked with Cache.unlock. s.mu must be held
+// for writing.
+func (s *shard) evictLocked(key string, entry *cacheEntry, reason EvictReason) {
+	s.removeLocked(key, entry)
+	s.evicted = append(s.evicted, evicted{key: key, entry: entry, reason: reason})
+}
+
+// unlock releases the write lock on s, makes room if the cache grew past its
+// limits while it was held, then runs the evict hooks for anything evicted.
+func (c *Cache) unlock(s *shard) {
+	pending := s.evicted
+	added := s.added
+	s.evicted = nil
+	s.added = nil
+	s.mu.Unlock()
+	if added != nil {
+		c.evictOverLimit(added)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	c.hooks.mu.RLock()
+	funcs := c.hooks.funcs
+	c.hooks.mu.RUnlock()
+	if len(funcs) == 0 {
+		return
+	}
+	for _, e := range pending {
+		val, err := e.entry.value()
+		if err != nil {
+			continue
+		}
+		for _, fn := range funcs {
+			fn(e.key, val, e.reason)
+		}
+	}
+}
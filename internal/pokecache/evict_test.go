@@ -0,0 +1,101 @@
+package pokecache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type evictRecord struct {
+	key    string
+	val    string
+	reason EvictReason
+}
+
+// recordEvictions collects what the evict hooks of cache are called with.
+func recordEvictions(cache *Cache) func() []evictRecord {
+	var mu sync.Mutex
+	var records []evictRecord
+	cache.OnEvict(func(key string, val []byte, reason EvictReason) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, evictRecord{key: key, val: string(val), reason: reason})
+	})
+	return func() []evictRecord {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]evictRecord(nil), records...)
+	}
+}
+
+func TestOnEvictExpired(t *testing.T) {
+	const interval = time.Minute
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(interval, WithClock(clock))
+	defer cache.Close()
+	records := recordEvictions(cache)
+
+	cache.Add("a", []byte("testdata"))
+	clock.Advance(interval + time.Second)
+
+	if !waitFor(t, func() bool { return len(records()) == 1 }) {
+		t.Fatalf("expected one eviction, got %v", records())
+	}
+	want := evictRecord{key: "a", val: "testdata", reason: EvictExpired}
+	if got := records()[0]; got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestOnEvictSize(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxEntries(1), WithCompression(1))
+	defer cache.Close()
+	records := recordEvictions(cache)
+
+	cache.Add("a", []byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+	// Replacing a value isn't an eviction
+	cache.Add("a", []byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"))
+	cache.Add("b", []byte("2"))
+
+	got := records()
+	// Hooks see the value decompressed
+	want := []evictRecord{{key: "a", val: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", reason: EvictSize}}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestOnEvictPurge(t *testing.T) {
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	records := recordEvictions(cache)
+
+	cache.Add("a", []byte("1"))
+	cache.Add("b", []byte("2"))
+	cache.Purge()
+
+	got := records()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 evictions, got %+v", got)
+	}
+	for _, record := range got {
+		if record.reason != EvictPurge {
+			t.Errorf("expected a purge, got %v", record.reason)
+		}
+	}
+}
+
+func TestOnEvictCanUseCache(t *testing.T) {
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	// Hooks run without the cache locked, so putting the value back is fine
+	cache.OnEvict(func(key string, val []byte, reason EvictReason) {
+		cache.Add("spilled/"+key, val)
+	})
+
+	cache.Add("a", []byte("1"))
+	cache.EvictPrefix("a")
+	if _, ok := cache.Get("spilled/a"); !ok {
+		t.Errorf("expected the hook to re-add the value")
+	}
+}
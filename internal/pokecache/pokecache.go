@@ -1,53 +1,423 @@
 package pokecache
 
 import (
+	"container/list"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type cacheEntry struct {
 	createdAt time.Time
 	val       []byte
+	// ttl is zero for entries that use the cache's default interval.
+	ttl        time.Duration
+	validators Validators
+	// decoded optionally holds val already parsed, see TypedCache. Once set,
+	// rawSize is added to size as an estimate of the memory it takes.
+	decoded any
+	// rawSize is the length of the value before any compression.
+	rawSize int
+	// compressed is set when val holds the gzipped form of the value.
+	compressed bool
+	// size is what the entry counts against the byte budget.
+	size int64
+	// elem is the entry's position in its shard's LRU list, and lastUsed
+	// orders it against entries in other shards.
+	elem     *list.Element
+	lastUsed atomic.Int64
 }
 
+// Cache stores raw values by key. Keys are spread over a number of shards,
+// each with its own lock, so concurrent readers rarely contend.
 type Cache struct {
-	cacheMap map[string]cacheEntry
-	mu       sync.Mutex
+	shards    []*shard
+	usage     usage
+	interval  time.Duration
+	disk      *diskStore
+	hooks     evictHooks
+	done      chan struct{}
+	closeOnce sync.Once
+	// reaperDone is closed once reapLoop has returned.
+	reaperDone chan struct{}
+
+	// Options, applied across the shards in NewCache
+	shardCount int
+	maxBytes   int64
+	maxEntries int
+
+	clock Clock
+	// logger reports disk store writes that fail, since the calls that make
+	// them have no error to return.
+	logger *log.Logger
+
+	// compressThreshold is the smallest value that gets compressed; zero disables it.
+	compressThreshold int
+
+	// staleTTL is how long past expiry an entry is kept, so that GetStale can
+	// still serve it and it can be revalidated rather than fetched again.
+	staleTTL time.Duration
+
+	// diskMaxAge is how long an entry is kept on disk after it was fetched
+	// or last revalidated; zero keeps it until it is purged.
+	diskMaxAge time.Duration
+}
+
+// Option configures optional Cache behaviour in NewCache.
+type Option func(*Cache)
+
+// WithDiskDir backs the cache with files in dir so entries survive restarts.
+// If dir can't be used the cache quietly falls back to memory only.
+func WithDiskDir(dir string) Option {
+	return func(c *Cache) {
+		store, err := openDiskStore(dir)
+		if err != nil {
+			return
+		}
+		c.disk = store
+	}
+}
+
+// WithDiskMaxAge drops entries from the disk store once maxAge has passed
+// since they were fetched or last revalidated. Expired entries are otherwise
+// kept on disk for use offline, so this is what bounds the disk store's
+// growth. It is checked when the cache is created and as the reaper runs.
+// Zero keeps entries until they're purged.
+func WithDiskMaxAge(maxAge time.Duration) Option {
+	return func(c *Cache) {
+		c.diskMaxAge = maxAge
+	}
+}
+
+// WithMaxBytes caps the memory used by cached keys and values at maxBytes,
+// evicting the least recently used entries to make room. Zero means no limit.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(c *Cache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithMaxEntries caps the number of entries held in memory, evicting the least
+// recently used ones to make room. Zero means no limit.
+func WithMaxEntries(maxEntries int) Option {
+	return func(c *Cache) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// WithStaleTTL keeps expired entries for an extra staleTTL so GetStale can
+// serve them while the caller revalidates in the background.
+func WithStaleTTL(staleTTL time.Duration) Option {
+	return func(c *Cache) {
+		c.staleTTL = staleTTL
+	}
+}
+
+// WithCompression gzips values of at least threshold bytes while they're held
+// in memory. Get returns them decompressed. Zero disables compression.
+func WithCompression(threshold int) Option {
+	return func(c *Cache) {
+		c.compressThreshold = threshold
+	}
+}
+
+// WithShards splits the cache into n independently locked shards. More
+// shards let more goroutines use the cache at once. The size limits still
+// apply to the cache as a whole.
+func WithShards(n int) Option {
+	return func(c *Cache) {
+		c.shardCount = n
+	}
+}
+
+// WithLogger sends errors writing to the disk store to logger. Without one
+// they are dropped, and the entries concerned are just fetched again after
+// a restart.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Cache) {
+		c.logger = logger
+	}
+}
+
+// WithClock makes the cache tell time with clock instead of the real clock.
+func WithClock(clock Clock) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// DefaultDir returns the on-disk cache location under the user's cache dir.
+func DefaultDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding user cache dir: %w", err)
+	}
+	return filepath.Join(cacheDir, "pokedex-go"), nil
 }
 
-func NewCache(interval time.Duration) *Cache {
-	newCache := Cache{cacheMap: make(map[string]cacheEntry), mu: sync.Mutex{}}
-	go newCache.reapLoop(interval)
+// NewCache returns a Cache whose entries expire after interval unless added
+// with a TTL of their own, and which reaps expired entries every interval.
+// A zero or negative interval means entries without a TTL never expire, and
+// nothing is reaped.
+func NewCache(interval time.Duration, opts ...Option) *Cache {
+	newCache := Cache{interval: interval, done: make(chan struct{}), reaperDone: make(chan struct{}), shardCount: defaultShards, clock: RealClock{}, logger: log.New(io.Discard, "", 0)}
+	for _, opt := range opts {
+		opt(&newCache)
+	}
+	if newCache.shardCount < 1 {
+		newCache.shardCount = 1
+	}
+	for i := 0; i < newCache.shardCount; i++ {
+		newCache.shards = append(newCache.shards, newShard(&newCache.usage))
+	}
+	// Drop anything that aged out while we weren't running
+	newCache.pruneDisk()
+	if interval <= 0 {
+		// Tickers need a positive period, and there'd be nothing to reap
+		close(newCache.reaperDone)
+		return &newCache
+	}
+	// Create the ticker before returning so no tick of a fake clock is missed
+	go newCache.reapLoop(interval, newCache.clock.NewTicker(interval))
 	return &newCache
 }
 
+// Add stores val under key for the cache's default interval.
 func (c *Cache) Add(key string, val []byte) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	entry := cacheEntry{val: val, createdAt: time.Now()}
-	c.cacheMap[key] = entry
+	c.AddWithTTL(key, val, 0)
+}
+
+// AddWithTTL stores val under key until ttl has passed. A zero ttl uses the
+// cache's default interval.
+func (c *Cache) AddWithTTL(key string, val []byte, ttl time.Duration) {
+	c.AddWithValidators(key, val, ttl, Validators{})
 }
 
+// Get returns the value stored under key if it hasn't expired.
 func (c *Cache) Get(key string) ([]byte, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	val, exists := c.cacheMap[key]
-	if exists {
-		return val.val, true
-	} else {
+	val, fresh, found := c.GetStale(key)
+	if !found || !fresh {
 		return nil, false
 	}
+	return val, true
+}
+
+// GetStale is like Get but also returns expired values that are still within
+// the stale window set by WithStaleTTL, reporting whether the value is fresh.
+// Callers can serve a stale value while they fetch a replacement.
+func (c *Cache) GetStale(key string) (val []byte, fresh bool, found bool) {
+	var err error
+	fresh, found = c.lookup(key, false, func(entry *cacheEntry) {
+		val, err = entry.value()
+	})
+	if !found || err != nil {
+		// A corrupt entry shouldn't happen, but it's no use to anyone
+		return nil, false, false
+	}
+	return val, fresh, true
 }
 
-func (c *Cache) reapLoop(interval time.Duration) {
-	ticker := time.Tick(interval)
-	for range ticker {
-		c.mu.Lock()
-		for key, value := range c.cacheMap {
-			if value.createdAt.Before(time.Now().Add(-interval)) {
-				delete(c.cacheMap, key)
-			}
+// Peek is like GetStale but isn't counted as a hit or a miss, for callers
+// checking again for a value they have already looked up.
+func (c *Cache) Peek(key string) (val []byte, fresh bool, found bool) {
+	var err error
+	fresh, found = c.find(key, false, func(entry *cacheEntry) {
+		val, err = entry.value()
+	})
+	if !found || err != nil {
+		return nil, false, false
+	}
+	return val, fresh, true
+}
+
+// lookup is find, counting the lookup as a hit if it found a fresh value and
+// as a miss otherwise.
+func (c *Cache) lookup(key string, anyAge bool, read func(*cacheEntry)) (fresh bool, found bool) {
+	fresh, found = c.find(key, anyAge, read)
+	s := c.shardFor(key)
+	if found && fresh {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+	return fresh, found
+}
+
+// find finds the entry for key, promoting it from disk if needed. Entries
+// past their stale window are not found unless anyAge is set. If the entry
+// is found, read is called with it while the shard is locked, so it can
+// safely copy out whatever the caller needs.
+func (c *Cache) find(key string, anyAge bool, read func(*cacheEntry)) (fresh bool, found bool) {
+	s := c.shardFor(key)
+	s.mu.RLock()
+	entry, exists := s.entries[key]
+	if !exists {
+		s.mu.RUnlock()
+		return c.findDisk(s, key, anyAge, read)
+	}
+	fresh, live := c.freshness(entry.createdAt, entry.ttl)
+	if !live && !anyAge {
+		s.mu.RUnlock()
+		return false, false
+	}
+	read(entry)
+	// Without limits nothing is evicted, so reads needn't reorder the LRU list
+	limited := c.limited()
+	if limited {
+		s.touch(entry)
+	}
+	reorder := limited && s.lru.Front() != entry.elem
+	s.mu.RUnlock()
+
+	if reorder {
+		s.moveToFront(key, entry)
+	}
+	return fresh, true
+}
+
+// findDisk is the slow path of find, for keys not held in memory. It falls
+// back to disk and promotes the entry, keeping its original age.
+func (c *Cache) findDisk(s *shard, key string, anyAge bool, read func(*cacheEntry)) (bool, bool) {
+	if c.disk == nil {
+		return false, false
+	}
+	s.mu.Lock()
+	defer c.unlock(s)
+	// Someone may have promoted it while we waited for the lock
+	entry, exists := s.entries[key]
+	if !exists {
+		diskEntry, found := c.disk.entry(key)
+		if !found {
+			return false, false
+		}
+		// Don't bother reading an entry the caller can't use
+		if _, live := c.freshness(diskEntry.CreatedAt, diskEntry.TTL); !live && !anyAge {
+			return false, false
 		}
-		c.mu.Unlock()
+		data, diskEntry, found := c.disk.get(key)
+		if !found {
+			return false, false
+		}
+		entry = c.newEntry(key, data, diskEntry.CreatedAt, diskEntry.TTL, diskEntry.validators())
+		s.storeLocked(key, entry)
+	}
+	fresh, live := c.freshness(entry.createdAt, entry.ttl)
+	if !live && !anyAge {
+		return false, false
+	}
+	read(entry)
+	s.touch(entry)
+	s.lru.MoveToFront(entry.elem)
+	return fresh, true
+}
+
+// freshness reports whether an entry created at createdAt with the given
+// ttl is fresh, and whether it is live at all, meaning fresh or still within
+// the stale window.
+func (c *Cache) freshness(createdAt time.Time, ttl time.Duration) (fresh bool, live bool) {
+	ttl = c.ttlFor(ttl)
+	if ttl <= 0 {
+		return true, true
+	}
+	now := c.clock.Now()
+	expiresAt := createdAt.Add(ttl)
+	return !now.After(expiresAt), !now.After(expiresAt.Add(c.staleTTL))
+}
+
+// Close stops the background reaper, waits for it to exit and flushes the
+// disk store. The cache can still be read and written afterwards, but entries
+// are no longer reaped. It is safe to call more than once.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	<-c.reaperDone
+	err := c.Flush()
+	if err != nil {
+		c.logger.Print(err)
+	}
+}
+
+// Flush writes out the disk store's index, so that everything added so far
+// is found on disk after a restart. It is also done as the reaper runs and
+// by Close, but callers adding many entries can flush after each batch.
+func (c *Cache) Flush() error {
+	if c.disk == nil {
+		return nil
+	}
+	err := c.disk.flush()
+	if err != nil {
+		return fmt.Errorf("error writing cache index: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) reapLoop(interval time.Duration, ticker Ticker) {
+	defer close(c.reaperDone)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C():
+		}
+		// Reap one shard at a time so lookups elsewhere carry on meanwhile
+		for _, s := range c.shards {
+			c.reapShard(s)
+		}
+		c.pruneDisk()
+		err := c.Flush()
+		if err != nil {
+			c.logger.Print(err)
+		}
+	}
+}
+
+func (c *Cache) reapShard(s *shard) {
+	s.mu.Lock()
+	defer c.unlock(s)
+	for key, entry := range s.entries {
+		// Keep expired entries around for as long as they may be served stale
+		if _, live := c.freshness(entry.createdAt, entry.ttl); !live {
+			s.evictLocked(key, entry, EvictExpired)
+			s.expirations.Add(1)
+		}
+	}
+}
+
+// pruneDisk drops disk entries older than the disk max age, if there is one.
+func (c *Cache) pruneDisk() {
+	if c.disk == nil || c.diskMaxAge <= 0 {
+		return
+	}
+	c.disk.removeOlderThan(c.clock.Now().Add(-c.diskMaxAge))
+}
+
+// newEntry builds the entry for val, compressing it if it's large enough.
+// It doesn't touch shared state, so it can run without holding a shard lock.
+func (c *Cache) newEntry(key string, val []byte, createdAt time.Time, ttl time.Duration, validators Validators) *cacheEntry {
+	entry := &cacheEntry{val: val, rawSize: len(val), createdAt: createdAt, ttl: ttl, validators: validators}
+	if c.compressThreshold > 0 && len(val) >= c.compressThreshold {
+		compressed, err := compress(val)
+		// Only keep the compressed form if it actually saves space
+		if err == nil && len(compressed) < len(val) {
+			entry.val = compressed
+			entry.compressed = true
+		}
+	}
+	entry.size = int64(len(key) + len(entry.val))
+	return entry
+}
+
+// ttlFor resolves an entry's ttl, where zero means the default interval.
+func (c *Cache) ttlFor(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		return c.interval
 	}
+	return ttl
 }
@@ -1,7 +1,13 @@
 package pokecache
 
 import (
+	"bytes"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,6 +31,7 @@ func TestAddGet(t *testing.T) {
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("Test case %v", i), func(t *testing.T) {
 			cache := NewCache(interval)
+			defer cache.Close()
 			cache.Add(c.key, c.val)
 			val, ok := cache.Get(c.key)
 			if !ok {
@@ -39,10 +46,26 @@ func TestAddGet(t *testing.T) {
 	}
 }
 
+// waitFor polls cond until it holds, for checks on the reaper goroutine that
+// can't be synchronised on directly.
+func waitFor(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
 func TestReapLoop(t *testing.T) {
 	const baseTime = 5 * time.Millisecond
 	const waitTime = baseTime + 5*time.Millisecond
-	cache := NewCache(baseTime)
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(baseTime, WithClock(clock))
+	defer cache.Close()
 	cache.Add("https://example.com", []byte("testdata"))
 
 	_, ok := cache.Get("https://example.com")
@@ -51,11 +74,376 @@ func TestReapLoop(t *testing.T) {
 		return
 	}
 
-	time.Sleep(waitTime)
+	clock.Advance(waitTime)
 
 	_, ok = cache.Get("https://example.com")
 	if ok {
 		t.Errorf("expected to not find key")
 		return
 	}
+	if !waitFor(t, func() bool { return cache.Stats().Expirations == 1 }) {
+		t.Errorf("expected the reaper to remove the key")
+	}
+}
+
+func TestReapLoopKeepsFreshEntries(t *testing.T) {
+	const interval = time.Minute
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(interval, WithClock(clock))
+	defer cache.Close()
+	cache.Add("old", []byte("testdata"))
+
+	clock.Advance(interval / 2)
+	cache.Add("new", []byte("testdata"))
+	clock.Advance(interval/2 + time.Second)
+
+	if !waitFor(t, func() bool { return cache.Stats().Expirations == 1 }) {
+		t.Fatalf("expected the reaper to remove the old key")
+	}
+	if _, ok := cache.Get("new"); !ok {
+		t.Errorf("expected the new key to survive the reaper")
+	}
+}
+
+func TestZeroInterval(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(0, WithClock(clock))
+	defer cache.Close()
+	cache.Add("https://example.com", []byte("testdata"))
+	cache.AddWithTTL("https://example.com/ttl", []byte("testdata"), time.Minute)
+
+	clock.Advance(time.Hour)
+	if _, ok := cache.Get("https://example.com"); !ok {
+		t.Errorf("expected entries without a TTL never to expire")
+	}
+	if _, ok := cache.Get("https://example.com/ttl"); ok {
+		t.Errorf("expected entries with a TTL to still expire")
+	}
+}
+
+func TestFakeClockRejectsNonPositivePeriod(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a zero period to panic")
+		}
+	}()
+	NewFakeClock(time.Now()).NewTicker(0)
+}
+
+func TestDiskPersistence(t *testing.T) {
+	const interval = 5 * time.Second
+	dir := t.TempDir()
+
+	cache := NewCache(interval, WithDiskDir(dir))
+	defer cache.Close()
+	cache.Add("https://example.com", []byte("testdata"))
+	// Closing writes out the index, as when the Pokedex exits
+	cache.Close()
+
+	// A fresh cache pointed at the same dir should see the entry
+	coldCache := NewCache(interval, WithDiskDir(dir))
+	defer coldCache.Close()
+	val, ok := coldCache.Get("https://example.com")
+	if !ok {
+		t.Errorf("expected to find key on disk")
+		return
+	}
+	if string(val) != "testdata" {
+		t.Errorf("expected to find value on disk")
+		return
+	}
+}
+
+func TestDiskReap(t *testing.T) {
+	const baseTime = 5 * time.Millisecond
+	const waitTime = baseTime + 5*time.Millisecond
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Now())
+
+	cache := NewCache(baseTime, WithDiskDir(dir), WithClock(clock))
+	defer cache.Close()
+	cache.Add("https://example.com", []byte("testdata"))
+	cache.Close()
+
+	coldCache := NewCache(baseTime, WithDiskDir(dir), WithClock(clock))
+	defer coldCache.Close()
+	_, ok := coldCache.Get("https://example.com")
+	if !ok {
+		t.Fatalf("expected to find key on disk")
+	}
+
+	clock.Advance(waitTime)
+
+	// A restart must not bring the expired entry back either
+	restarted := NewCache(baseTime, WithDiskDir(dir), WithClock(clock))
+	defer restarted.Close()
+	for _, c := range []*Cache{coldCache, restarted} {
+		_, ok = c.Get("https://example.com")
+		if ok {
+			t.Errorf("expected to not find expired key on disk")
+		}
+	}
+}
+
+func TestDiskKeepsExpiredEntries(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(time.Minute, WithDiskDir(dir), WithClock(clock), WithStaleTTL(time.Minute))
+	defer cache.Close()
+	cache.Add("https://example.com", []byte("testdata"))
+
+	cache.Close()
+
+	// Well past the stale window, and with the reaper having been round
+	clock.Advance(time.Hour)
+	coldCache := NewCache(time.Minute, WithDiskDir(dir), WithClock(clock), WithStaleTTL(time.Minute))
+	defer coldCache.Close()
+	clock.Advance(time.Minute)
+
+	if _, _, ok := coldCache.GetStale("https://example.com"); ok {
+		t.Errorf("expected the entry to be past its stale window")
+	}
+	typed := NewTypedCache(coldCache, func(raw []byte) (string, error) { return string(raw), nil })
+	val, fresh, ok := typed.GetAnyAge("https://example.com")
+	if !ok || fresh || val != "testdata" {
+		t.Errorf("expected the expired entry to be kept on disk, got %q, %v, %v", val, fresh, ok)
+	}
+	if stats := coldCache.Stats(); stats.DiskEntries != 1 {
+		t.Errorf("expected 1 disk entry, got %d", stats.DiskEntries)
+	}
+}
+
+func TestDiskMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewFakeClock(time.Now())
+	newCache := func() *Cache {
+		cache := NewCache(time.Minute, WithDiskDir(dir), WithClock(clock), WithDiskMaxAge(time.Hour))
+		t.Cleanup(cache.Close)
+		return cache
+	}
+	cache := newCache()
+	cache.Add("old", []byte("testdata"))
+	clock.Advance(30 * time.Minute)
+	cache.Add("new", []byte("testdata"))
+	cache.Close()
+
+	// Starting up drops what aged out while we weren't running
+	clock.Advance(45 * time.Minute)
+	coldCache := newCache()
+	if stats := coldCache.Stats(); stats.DiskEntries != 1 {
+		t.Errorf("expected only the new entry to be left on disk, got %d", stats.DiskEntries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskFileName("old"))); !os.IsNotExist(err) {
+		t.Errorf("expected the old entry's file to be removed, got %v", err)
+	}
+
+	// and so does the reaper
+	clock.Advance(30 * time.Minute)
+	if !waitFor(t, func() bool { return coldCache.Stats().DiskEntries == 0 }) {
+		t.Errorf("expected the reaper to remove the new entry from disk")
+	}
+}
+
+func TestDiskWriteErrorsAreLogged(t *testing.T) {
+	dir := t.TempDir()
+	var logged bytes.Buffer
+	cache := NewCache(time.Minute, WithDiskDir(dir), WithLogger(log.New(&logged, "", 0)))
+	defer cache.Close()
+	// Pull the dir out from under the cache so writes to it fail
+	err := os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Add("https://example.com", []byte("testdata"))
+	if !strings.Contains(logged.String(), "error writing https://example.com") {
+		t.Errorf("expected the failed write to be logged, got %q", logged.String())
+	}
+	if _, ok := cache.Get("https://example.com"); !ok {
+		t.Errorf("expected the entry to still be cached in memory")
+	}
+}
+
+func TestDiskIndexWrittenOnFlush(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(time.Minute, WithDiskDir(dir))
+	defer cache.Close()
+
+	indexPath := filepath.Join(dir, indexFile)
+	for i := 0; i < 10; i++ {
+		cache.Add(fmt.Sprintf("https://example.com/%d", i), []byte("testdata"))
+	}
+	if _, err := os.Stat(indexPath); err == nil {
+		t.Errorf("expected adding entries not to write the index")
+	}
+
+	err := cache.Flush()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	coldCache := NewCache(time.Minute, WithDiskDir(dir))
+	defer coldCache.Close()
+	if stats := coldCache.Stats(); stats.DiskEntries != 10 {
+		t.Errorf("expected the flushed index to list 10 entries, got %d", stats.DiskEntries)
+	}
+}
+
+func TestCloseStopsReaper(t *testing.T) {
+	const caches = 50
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < caches; i++ {
+		cache := NewCache(time.Millisecond)
+		cache.Close()
+		// Closing twice must be harmless
+		cache.Close()
+	}
+
+	// Give the reapers a moment to notice they've been closed
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected reaper goroutines to exit, %d leaked", after-before)
+	}
+}
+
+func TestMaxEntriesEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxEntries(2))
+	defer cache.Close()
+
+	cache.Add("a", []byte("1"))
+	cache.Add("b", []byte("2"))
+	// Touch a so that b becomes the least recently used
+	cache.Get("a")
+	cache.Add("c", []byte("3"))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected to find %s", key)
+		}
+	}
+}
+
+func TestMaxBytesEvictsLeastRecentlyUsed(t *testing.T) {
+	// Each entry is a one byte key plus a nine byte value
+	cache := NewCache(time.Minute, WithMaxBytes(25))
+	defer cache.Close()
+
+	cache.Add("a", []byte("123456789"))
+	cache.Add("b", []byte("123456789"))
+	cache.Add("c", []byte("123456789"))
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected a to be evicted")
+	}
+	if bytes := cache.Stats().Bytes; bytes != 20 {
+		t.Errorf("expected 20 bytes in use, got %d", bytes)
+	}
+
+	// Replacing an entry must not count it twice
+	cache.Add("c", []byte("1"))
+	if bytes := cache.Stats().Bytes; bytes != 12 {
+		t.Errorf("expected 12 bytes in use, got %d", bytes)
+	}
+}
+
+func TestOversizedEntryIsKept(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxBytes(4))
+	defer cache.Close()
+
+	cache.Add("a", []byte("far too large"))
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("expected the newest entry to be kept")
+	}
+}
+
+func TestPerEntryTTL(t *testing.T) {
+	const baseTime = 5 * time.Millisecond
+	const waitTime = baseTime + 5*time.Millisecond
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(baseTime, WithClock(clock))
+	defer cache.Close()
+
+	cache.Add("short", []byte("testdata"))
+	cache.AddWithTTL("long", []byte("testdata"), time.Minute)
+
+	clock.Advance(waitTime)
+	if !waitFor(t, func() bool { return cache.Stats().Expirations == 1 }) {
+		t.Errorf("expected the reaper to remove the short ttl entry")
+	}
+
+	if _, ok := cache.Get("short"); ok {
+		t.Errorf("expected default ttl entry to expire")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Errorf("expected long ttl entry to survive the reaper")
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	const baseTime = 5 * time.Millisecond
+	const waitTime = baseTime + 5*time.Millisecond
+	clock := NewFakeClock(time.Now())
+	cache := NewCache(time.Minute, WithStaleTTL(time.Minute), WithClock(clock))
+	defer cache.Close()
+
+	cache.AddWithTTL("https://example.com", []byte("testdata"), baseTime)
+	_, fresh, found := cache.GetStale("https://example.com")
+	if !found || !fresh {
+		t.Errorf("expected a fresh value")
+	}
+
+	clock.Advance(waitTime)
+
+	if _, ok := cache.Get("https://example.com"); ok {
+		t.Errorf("expected Get to ignore stale values")
+	}
+	val, fresh, found := cache.GetStale("https://example.com")
+	if !found || fresh {
+		t.Errorf("expected a stale value")
+		return
+	}
+	if string(val) != "testdata" {
+		t.Errorf("expected to find stale value")
+	}
+}
+
+func TestRefreshFromDisk(t *testing.T) {
+	const baseTime = 5 * time.Millisecond
+	const waitTime = baseTime + 5*time.Millisecond
+	dir := t.TempDir()
+
+	clock := NewFakeClock(time.Now())
+
+	cache := NewCache(time.Minute, WithDiskDir(dir), WithStaleTTL(time.Minute), WithClock(clock))
+	defer cache.Close()
+	cache.AddWithValidators("https://example.com", []byte("testdata"), baseTime, Validators{ETag: `"v1"`})
+	cache.Close()
+
+	clock.Advance(waitTime)
+
+	coldCache := NewCache(time.Minute, WithDiskDir(dir), WithStaleTTL(time.Minute), WithClock(clock))
+	defer coldCache.Close()
+	validators, ok := coldCache.GetValidators("https://example.com")
+	if !ok || validators.ETag != `"v1"` {
+		t.Errorf("expected to find validators on disk, got %+v", validators)
+		return
+	}
+	if _, ok := coldCache.Get("https://example.com"); ok {
+		t.Errorf("expected entry to have expired")
+		return
+	}
+	if !coldCache.Refresh("https://example.com") {
+		t.Errorf("expected refresh to find the entry")
+		return
+	}
+	val, ok := coldCache.Get("https://example.com")
+	if !ok || string(val) != "testdata" {
+		t.Errorf("expected refreshed entry to be fresh")
+	}
 }
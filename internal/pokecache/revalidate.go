@@ -0,0 +1,83 @@
+package pokecache
+
+import "time"
+
+// Validators are the HTTP response headers needed to ask the server whether
+// a cached response is still current.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// IsZero reports whether there is nothing to revalidate with.
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// AddWithValidators is like AddWithTTL but also remembers the validators the
+// response came with, so it can be revalidated once it expires.
+func (c *Cache) AddWithValidators(key string, val []byte, ttl time.Duration, validators Validators) {
+	createdAt := c.clock.Now()
+	entry := c.newEntry(key, val, createdAt, ttl, validators)
+	s := c.shardFor(key)
+	s.mu.Lock()
+	s.storeLocked(key, entry)
+	c.unlock(s)
+	if c.disk != nil {
+		err := c.disk.put(key, val, createdAt, ttl, validators)
+		if err != nil {
+			c.logger.Printf("error writing %s to the cache dir: %v", key, err)
+		}
+	}
+}
+
+// GetValidators returns the validators stored with key, including for entries
+// that have expired. However old they are, the server can still say they're current.
+func (c *Cache) GetValidators(key string) (Validators, bool) {
+	s := c.shardFor(key)
+	s.mu.RLock()
+	entry, exists := s.entries[key]
+	var validators Validators
+	if exists {
+		validators = entry.validators
+	}
+	s.mu.RUnlock()
+	if exists {
+		return validators, true
+	}
+	if c.disk != nil {
+		diskEntry, exists := c.disk.entry(key)
+		if exists {
+			return diskEntry.validators(), true
+		}
+	}
+	return Validators{}, false
+}
+
+// Refresh marks the entry for key as fresh again, as when the server answers
+// a revalidation with 304 Not Modified. It reports whether the entry was found.
+func (c *Cache) Refresh(key string) bool {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer c.unlock(s)
+	now := c.clock.Now()
+	entry, exists := s.entries[key]
+	if !exists && c.disk != nil {
+		// Bring the entry back into memory from disk first
+		data, diskEntry, found := c.disk.get(key)
+		if found {
+			s.storeLocked(key, c.newEntry(key, data, diskEntry.CreatedAt, diskEntry.TTL, diskEntry.validators()))
+			entry, exists = s.entries[key]
+		}
+	}
+	if !exists {
+		return false
+	}
+	entry.createdAt = now
+	s.touch(entry)
+	s.lru.MoveToFront(entry.elem)
+	if c.disk != nil {
+		c.disk.touch(key, now)
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package pokecache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultShards is how many shards a Cache is split into unless WithShards
+// says otherwise.
+const defaultShards = 16
+
+// shard holds a slice of the cache's keys behind its own lock, so lookups of
+// keys in different shards never wait on each other. Each shard keeps its own
+// LRU order; the size limits apply to the cache as a whole, see evictOverLimit.
+type shard struct {
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+	// lru orders keys from most (front) to least (back) recently used.
+	lru   *list.List
+	bytes int64
+	// usage is the cache-wide total this shard adds to.
+	usage *usage
+
+	// Counters reported by Stats. They're atomic so that readers holding
+	// only the read lock can update them.
+	hits        atomic.Int64
+	misses      atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+
+	// evicted queues removed entries for the evict hooks until the write
+	// lock is released, and added is the entry that grew the cache while it
+	// was held, which mustn't be evicted to make room for itself.
+	evicted []evicted
+	added   *cacheEntry
+}
+
+// usage is how much the whole cache holds in memory, kept up to date by
+// every shard so the size limits can be checked without locking them all.
+type usage struct {
+	entries atomic.Int64
+	bytes   atomic.Int64
+	// tick orders uses of entries across shards, see cacheEntry.lastUsed.
+	tick atomic.Int64
+}
+
+func newShard(usage *usage) *shard {
+	return &shard{
+		entries: make(map[string]*cacheEntry),
+		lru:     list.New(),
+		usage:   usage,
+	}
+}
+
+// shardFor returns the shard that owns key.
+func (c *Cache) shardFor(key string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	// FNV-1a, written out so hashing a key doesn't allocate
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// limited reports whether the cache ever evicts, and so needs its LRU order
+// kept up to date on reads.
+func (c *Cache) limited() bool {
+	return c.maxBytes > 0 || c.maxEntries > 0
+}
+
+func (c *Cache) overLimit() bool {
+	if c.maxEntries > 0 && c.usage.entries.Load() > int64(c.maxEntries) {
+		return true
+	}
+	return c.maxBytes > 0 && c.usage.bytes.Load() > c.maxBytes
+}
+
+// storeLocked puts entry in memory as the most recently used. If that takes
+// the cache over its limits, room is made once the shard is unlocked.
+// s.mu must be held for writing, and released with Cache.unlock.
+func (s *shard) storeLocked(key string, entry *cacheEntry) {
+	old, exists := s.entries[key]
+	if exists {
+		s.removeLocked(key, old)
+	}
+	entry.elem = s.lru.PushFront(key)
+	entry.lastUsed.Store(s.usage.tick.Add(1))
+	s.entries[key] = entry
+	s.bytes += entry.size
+	s.usage.entries.Add(1)
+	s.usage.bytes.Add(entry.size)
+	s.added = entry
+}
+
+// growLocked adds delta to what entry counts against the byte limit. If that
+// takes the cache over its limits, room is made once the shard is unlocked.
+// s.mu must be held for writing, and released with Cache.unlock.
+func (s *shard) growLocked(entry *cacheEntry, delta int64) {
+	entry.size += delta
+	s.bytes += delta
+	s.usage.bytes.Add(delta)
+	s.added = entry
+}
+
+// removeLocked drops an entry from memory. s.mu must be held for writing.
+func (s *shard) removeLocked(key string, entry *cacheEntry) {
+	s.lru.Remove(entry.elem)
+	delete(s.entries, key)
+	s.bytes -= entry.size
+	s.usage.entries.Add(-1)
+	s.usage.bytes.Add(-entry.size)
+}
+
+// touch marks entry as the most recently used. s.mu must be held, for
+// reading at least; the LRU list itself is reordered by moveToFront.
+func (s *shard) touch(entry *cacheEntry) {
+	entry.lastUsed.Store(s.usage.tick.Add(1))
+}
+
+// moveToFront marks entry as the most recently used, if it is still the one
+// stored under key. It takes the write lock itself.
+func (s *shard) moveToFront(key string, entry *cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[key] == entry {
+		s.lru.MoveToFront(entry.elem)
+	}
+}
+
+// oldestLocked returns the shard's least recently used entry other than
+// keep, if it has one. s.mu must be held, for reading at least.
+func (s *shard) oldestLocked(keep *cacheEntry) (string, *cacheEntry, bool) {
+	elem := s.lru.Back()
+	if elem != nil && s.entries[elem.Value.(string)] == keep {
+		elem = elem.Prev()
+	}
+	if elem == nil {
+		return "", nil, false
+	}
+	key := elem.Value.(string)
+	return key, s.entries[key], true
+}
+
+// evictOverLimit evicts the least recently used entries across all shards,
+// sparing keep, until the cache is back within its limits. No shard lock may
+// be held, as it locks each shard in turn.
+func (c *Cache) evictOverLimit(keep *cacheEntry) {
+	for c.overLimit() {
+		// Each shard's oldest entry is at the back of its list, so the
+		// cache's oldest is whichever of those was used longest ago
+		var victim *shard
+		var oldest int64
+		for _, s := range c.shards {
+			s.mu.RLock()
+			_, entry, found := s.oldestLocked(keep)
+			if found && (victim == nil || entry.lastUsed.Load() < oldest) {
+				victim, oldest = s, entry.lastUsed.Load()
+			}
+			s.mu.RUnlock()
+		}
+		if victim == nil {
+			// Never evict the entry in hand, even if it alone is over budget
+			return
+		}
+
+		victim.mu.Lock()
+		// It may have changed since we looked, but any old entry will do
+		key, entry, found := victim.oldestLocked(keep)
+		if found {
+			victim.evictLocked(key, entry, EvictSize)
+			victim.evictions.Add(1)
+		}
+		c.unlock(victim)
+	}
+}
@@ -0,0 +1,150 @@
+package pokecache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardsSpreadKeys(t *testing.T) {
+	cache := NewCache(time.Minute, WithShards(4))
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), []byte("testdata"))
+	}
+	for i, s := range cache.shards {
+		if len(s.entries) == 0 {
+			t.Errorf("expected shard %d to hold some keys", i)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 100 {
+		t.Errorf("expected 100 entries, got %d", stats.Entries)
+	}
+}
+
+func TestShardsShareLimits(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  []Option
+		check func(Stats) bool
+	}{
+		{name: "entries", opts: []Option{WithMaxEntries(2)}, check: func(s Stats) bool { return s.Entries == 2 }},
+		// Each entry is a six byte key plus an eight byte value
+		{name: "bytes", opts: []Option{WithMaxBytes(50)}, check: func(s Stats) bool { return s.Bytes <= 50 && s.Entries == 3 }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cache := NewCache(time.Minute, append(c.opts, WithShards(defaultShards))...)
+			defer cache.Close()
+
+			for i := 0; i < 100; i++ {
+				cache.Add(fmt.Sprintf("key-%02d", i), []byte("testdata"))
+			}
+			// The limits cover the whole cache, however few keys each shard holds
+			stats := cache.Stats()
+			if !c.check(stats) {
+				t.Errorf("expected the limit to hold across shards, got %+v", stats)
+			}
+			// and the entries kept are the most recently used
+			if _, ok := cache.Get("key-99"); !ok {
+				t.Errorf("expected the newest entry to be kept")
+			}
+		})
+	}
+}
+
+func TestShardsEvictLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxEntries(3))
+	defer cache.Close()
+
+	for i := 0; i < 3; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), []byte("testdata"))
+	}
+	cache.Get("key-0")
+	cache.Add("key-3", []byte("testdata"))
+
+	// key-1 is the oldest wherever the keys happen to be sharded
+	if _, ok := cache.Get("key-1"); ok {
+		t.Errorf("expected key-1 to be evicted")
+	}
+	for _, key := range []string{"key-0", "key-2", "key-3"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected to find %s", key)
+		}
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxEntries(50))
+	defer cache.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key-%d", i%100)
+				if i%4 == 0 {
+					cache.Add(key, []byte("testdata"))
+				} else {
+					cache.Get(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	if stats.Hits+stats.Misses != 8*750 {
+		t.Errorf("expected every lookup to be counted, got %d", stats.Hits+stats.Misses)
+	}
+	if stats.Entries > 50 {
+		t.Errorf("expected at most 50 entries, got %d", stats.Entries)
+	}
+}
+
+// benchmarkParallel measures throughput with every CPU hitting the cache at
+// once. One in writeEvery operations is an Add; the rest are Gets.
+func benchmarkParallel(b *testing.B, shards int, writeEvery int) {
+	const keys = 1024
+	cache := NewCache(time.Minute, WithShards(shards))
+	defer cache.Close()
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = fmt.Sprintf("https://pokeapi.co/api/v2/pokemon/%d/", i)
+		cache.Add(names[i], []byte("testdata"))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := names[i%keys]
+			if writeEvery > 0 && i%writeEvery == 0 {
+				cache.Add(key, []byte("testdata"))
+			} else {
+				cache.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkParallel(b, shards, 0)
+		})
+	}
+}
+
+func BenchmarkParallelMixed(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkParallel(b, shards, 10)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package pokecache
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats is a snapshot of how the cache is performing.
+type Stats struct {
+	// Hits and Misses count lookups that did and didn't find a fresh value.
+	Hits   int
+	Misses int
+	// Entries and Bytes describe what is held in memory.
+	Entries int
+	Bytes   int64
+	// DiskEntries is how many entries the disk store holds, if there is one.
+	DiskEntries int
+	// Evictions counts entries dropped to stay within the size limits, and
+	// Expirations those dropped by the reaper for being too old.
+	Evictions   int
+	Expirations int
+	// OldestAge is the age of the oldest entry in memory.
+	OldestAge time.Duration
+}
+
+// EntryInfo describes a single entry held in memory.
+type EntryInfo struct {
+	Key   string
+	Bytes int64
+	Age   time.Duration
+	Fresh bool
+}
+
+// Stats returns the cache's current counters and sizes.
+func (c *Cache) Stats() Stats {
+	var stats Stats
+	now := c.clock.Now()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		stats.Entries += len(s.entries)
+		stats.Bytes += s.bytes
+		for _, entry := range s.entries {
+			stats.OldestAge = max(stats.OldestAge, now.Sub(entry.createdAt))
+		}
+		s.mu.RUnlock()
+		stats.Hits += int(s.hits.Load())
+		stats.Misses += int(s.misses.Load())
+		stats.Evictions += int(s.evictions.Load())
+		stats.Expirations += int(s.expirations.Load())
+	}
+	if c.disk != nil {
+		stats.DiskEntries = c.disk.len()
+	}
+	return stats
+}
+
+// Entries lists what is held in memory, sorted by key.
+func (c *Cache) Entries() []EntryInfo {
+	var entries []EntryInfo
+	now := c.clock.Now()
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, entry := range s.entries {
+			fresh, _ := c.freshness(entry.createdAt, entry.ttl)
+			entries = append(entries, EntryInfo{
+				Key:   key,
+				Bytes: entry.size,
+				Age:   now.Sub(entry.createdAt),
+				Fresh: fresh,
+			})
+		}
+		s.mu.RUnlock()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// Purge removes every entry, from memory and disk, and returns how many
+// distinct keys were removed.
+func (c *Cache) Purge() int {
+	return c.EvictPrefix("")
+}
+
+// Remove removes the entry for key, from memory and disk, and reports whether
+// there was one.
+func (c *Cache) Remove(key string) bool {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	entry, removed := s.entries[key]
+	if removed {
+		s.evictLocked(key, entry, EvictPurge)
+	}
+	c.unlock(s)
+	if c.disk != nil && c.disk.remove(key) {
+		removed = true
+	}
+	return removed
+}
+
+// EvictPrefix removes every entry whose key starts with prefix, from memory
+// and disk, and returns how many distinct keys were removed.
+func (c *Cache) EvictPrefix(prefix string) int {
+	removed := make(map[string]bool)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, entry := range s.entries {
+			if strings.HasPrefix(key, prefix) {
+				s.evictLocked(key, entry, EvictPurge)
+				removed[key] = true
+			}
+		}
+		c.unlock(s)
+	}
+	if c.disk != nil {
+		for _, key := range c.disk.removePrefix(prefix) {
+			removed[key] = true
+		}
+	}
+	return len(removed)
+}
@@ -0,0 +1,88 @@
+package pokecache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	cache := NewCache(time.Minute, WithMaxEntries(2))
+	defer cache.Close()
+
+	cache.Add("a", []byte("1"))
+	cache.Add("b", []byte("22"))
+	cache.Get("a")
+	cache.Get("missing")
+	// Peeking doesn't count either way
+	cache.Peek("a")
+	cache.Peek("missing")
+	cache.Add("c", []byte("333"))
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected 1 hit and 1 miss, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("expected 2 entries and 1 eviction, got %d and %d", stats.Entries, stats.Evictions)
+	}
+	// a and c remain: one byte keys with one and three byte values
+	if stats.Bytes != 6 {
+		t.Errorf("expected 6 bytes, got %d", stats.Bytes)
+	}
+}
+
+func TestEvictPrefix(t *testing.T) {
+	cache := NewCache(time.Minute, WithDiskDir(t.TempDir()))
+	defer cache.Close()
+
+	cache.Add("https://example.com/pokemon/pikachu/", []byte("testdata"))
+	cache.Add("https://example.com/pokemon/eevee/", []byte("testdata"))
+	cache.Add("https://example.com/location-area/", []byte("testdata"))
+
+	removed := cache.EvictPrefix("https://example.com/pokemon/")
+	if removed != 2 {
+		t.Errorf("expected 2 keys removed, got %d", removed)
+	}
+	if _, ok := cache.Get("https://example.com/pokemon/eevee/"); ok {
+		t.Errorf("expected evicted key to be gone from memory and disk")
+	}
+	if _, ok := cache.Get("https://example.com/location-area/"); !ok {
+		t.Errorf("expected other keys to be kept")
+	}
+
+	removed = cache.Purge()
+	if removed != 1 {
+		t.Errorf("expected 1 key purged, got %d", removed)
+	}
+	stats := cache.Stats()
+	if stats.Entries != 0 || stats.DiskEntries != 0 || stats.Bytes != 0 {
+		t.Errorf("expected an empty cache, got %+v", stats)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(time.Minute, WithDiskDir(dir))
+	defer cache.Close()
+
+	cache.Add("https://example.com/pokemon/", []byte("testdata"))
+	cache.Add("https://example.com/pokemon/pikachu/", []byte("testdata"))
+
+	if !cache.Remove("https://example.com/pokemon/") {
+		t.Errorf("expected the key to be removed")
+	}
+	if cache.Remove("https://example.com/pokemon/") {
+		t.Errorf("expected nothing left to remove")
+	}
+	cache.Close()
+
+	// Only the exact key goes, and it doesn't come back from disk
+	coldCache := NewCache(time.Minute, WithDiskDir(dir))
+	defer coldCache.Close()
+	if _, ok := coldCache.Get("https://example.com/pokemon/"); ok {
+		t.Errorf("expected the removed key to be gone from disk")
+	}
+	if _, ok := coldCache.Get("https://example.com/pokemon/pikachu/"); !ok {
+		t.Errorf("expected keys it prefixes to be kept")
+	}
+}
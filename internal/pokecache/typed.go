@@ -0,0 +1,103 @@
+package pokecache
+
+// TypedCache sits in front of a Cache and remembers the decoded form of each
+// value, so repeat lookups skip parsing the raw bytes again. The raw bytes
+// stay in the underlying Cache, which still handles expiry, eviction and the
+// disk store; a decoded value lives exactly as long as the entry it came from.
+// Each decoded value counts against the Cache's byte limit as if it took as
+// much memory as the uncompressed raw value.
+//
+// Decoded values are shared between callers and must not be modified.
+type TypedCache[T any] struct {
+	cache  *Cache
+	decode func([]byte) (T, error)
+}
+
+// NewTypedCache returns a TypedCache over cache that parses raw values with decode.
+func NewTypedCache[T any](cache *Cache, decode func([]byte) (T, error)) *TypedCache[T] {
+	return &TypedCache[T]{cache: cache, decode: decode}
+}
+
+// Get returns the decoded value for key if it hasn't expired.
+func (tc *TypedCache[T]) Get(key string) (T, bool) {
+	val, fresh, found := tc.GetStale(key)
+	if !found || !fresh {
+		var zero T
+		return zero, false
+	}
+	return val, true
+}
+
+// GetStale is the TypedCache counterpart of Cache.GetStale. Values that fail
+// to decode are reported as not found.
+func (tc *TypedCache[T]) GetStale(key string) (val T, fresh bool, found bool) {
+	return tc.get(key, false)
+}
+
+// GetAnyAge is like GetStale but also returns values past the stale window,
+// for when an outdated value beats none, such as while offline. The disk
+// store keeps values until they're replaced, so they can be this old.
+func (tc *TypedCache[T]) GetAnyAge(key string) (val T, fresh bool, found bool) {
+	return tc.get(key, true)
+}
+
+func (tc *TypedCache[T]) get(key string, anyAge bool) (val T, fresh bool, found bool) {
+	var entry *cacheEntry
+	var decoded T
+	var haveDecoded bool
+	var raw []byte
+	var err error
+	fresh, found = tc.cache.lookup(key, anyAge, func(e *cacheEntry) {
+		entry = e
+		decoded, haveDecoded = e.decoded.(T)
+		if !haveDecoded {
+			raw, err = e.value()
+		}
+	})
+	if !found || err != nil {
+		return val, false, false
+	}
+	if haveDecoded {
+		return decoded, fresh, true
+	}
+
+	// Decode without holding the lock; it's the slow part
+	val, err = tc.decode(raw)
+	if err != nil {
+		return val, false, false
+	}
+	tc.set(key, entry, val)
+	return val, fresh, true
+}
+
+// Attach records val as the decoded form of the value currently stored under
+// key, typically right after the raw bytes were added to the underlying Cache.
+// It does nothing if key isn't cached.
+func (tc *TypedCache[T]) Attach(key string, val T) {
+	s := tc.cache.shardFor(key)
+	s.mu.Lock()
+	defer tc.cache.unlock(s)
+	entry, exists := s.entries[key]
+	if exists {
+		attachLocked(s, entry, val)
+	}
+}
+
+// set attaches val to entry, unless entry has been replaced in the meantime.
+func (tc *TypedCache[T]) set(key string, entry *cacheEntry, val T) {
+	s := tc.cache.shardFor(key)
+	s.mu.Lock()
+	defer tc.cache.unlock(s)
+	if s.entries[key] == entry {
+		attachLocked(s, entry, val)
+	}
+}
+
+// attachLocked stores val as entry's decoded form, counting it against the
+// byte limit the first time. s.mu must be held for writing.
+func attachLocked(s *shard, entry *cacheEntry, val any) {
+	if entry.decoded == nil {
+		s.growLocked(entry, int64(entry.rawSize))
+	}
+	entry.decoded = val
+}
@@ -0,0 +1,116 @@
+package pokecache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPokemon struct {
+	Name string `json:"name"`
+}
+
+func TestTypedCacheDecodesOnce(t *testing.T) {
+	cache := NewCache(time.Minute, WithCompression(1))
+	defer cache.Close()
+	decodes := 0
+	typed := NewTypedCache(cache, func(raw []byte) (testPokemon, error) {
+		decodes++
+		var p testPokemon
+		err := json.Unmarshal(raw, &p)
+		return p, err
+	})
+
+	cache.Add("pikachu", []byte(`{"name": "pikachu"}`))
+	for i := 0; i < 3; i++ {
+		p, ok := typed.Get("pikachu")
+		if !ok || p.Name != "pikachu" {
+			t.Fatalf("expected to find pikachu, got %+v", p)
+		}
+	}
+	if decodes != 1 {
+		t.Errorf("expected a single decode, got %d", decodes)
+	}
+
+	// Replacing the raw bytes must drop the stale decoded value
+	cache.Add("pikachu", []byte(`{"name": "raichu"}`))
+	p, ok := typed.Get("pikachu")
+	if !ok || p.Name != "raichu" {
+		t.Errorf("expected the new value, got %+v", p)
+	}
+	if decodes != 2 {
+		t.Errorf("expected a second decode, got %d", decodes)
+	}
+}
+
+func TestTypedCacheAttach(t *testing.T) {
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	typed := NewTypedCache(cache, func(raw []byte) (testPokemon, error) {
+		t.Errorf("expected attached value to be used without decoding")
+		return testPokemon{}, nil
+	})
+
+	cache.Add("pikachu", []byte(`{"name": "pikachu"}`))
+	typed.Attach("pikachu", testPokemon{Name: "pikachu"})
+	p, ok := typed.Get("pikachu")
+	if !ok || p.Name != "pikachu" {
+		t.Errorf("expected to find pikachu, got %+v", p)
+	}
+}
+
+func TestTypedCacheBadValue(t *testing.T) {
+	cache := NewCache(time.Minute)
+	defer cache.Close()
+	typed := NewTypedCache(cache, func(raw []byte) (testPokemon, error) {
+		var p testPokemon
+		err := json.Unmarshal(raw, &p)
+		return p, err
+	})
+
+	cache.Add("pikachu", []byte(`not json`))
+	if _, ok := typed.Get("pikachu"); ok {
+		t.Errorf("expected undecodable value to be a miss")
+	}
+}
+
+func TestTypedCacheCountsDecodedSize(t *testing.T) {
+	raw := []byte(`{"name": "pikachu", "padding": "` + strings.Repeat("x", 100) + `"}`)
+	entrySize := int64(1 + len(raw))
+	cache := NewCache(time.Minute, WithMaxBytes(3*entrySize), WithCompression(1))
+	defer cache.Close()
+	typed := NewTypedCache(cache, func(raw []byte) (testPokemon, error) {
+		var p testPokemon
+		err := json.Unmarshal(raw, &p)
+		return p, err
+	})
+
+	cache.Add("a", raw)
+	cache.Add("b", raw)
+	compressed := cache.Stats().Bytes
+	if compressed >= 2*entrySize {
+		t.Fatalf("expected the values to be compressed, got %d bytes", compressed)
+	}
+	typed.Get("a")
+	if got := cache.Stats().Bytes; got != compressed+int64(len(raw)) {
+		t.Errorf("expected the decoded value to count as %d bytes, got %d more", len(raw), got-compressed)
+	}
+	typed.Get("a")
+	if got := cache.Stats().Bytes; got != compressed+int64(len(raw)) {
+		t.Errorf("expected the decoded value to be counted once, got %d bytes", got)
+	}
+
+	// A third decoded value is over budget, so the least recently used goes
+	typed.Get("b")
+	typed.Get("a")
+	cache.Add("c", raw)
+	typed.Get("c")
+	stats := cache.Stats()
+	if stats.Bytes > 3*entrySize || stats.Evictions == 0 {
+		t.Errorf("expected decoded values to count against the byte limit, got %+v", stats)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+}
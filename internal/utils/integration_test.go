@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/fakepokeapi"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+)
+
+// newFakeAPIClient returns a client talking to a fake PokeAPI server.
+func newFakeAPIClient(t *testing.T) *pokeapi.Client {
+	t.Helper()
+	server := fakepokeapi.NewServer()
+	t.Cleanup(server.Close)
+	cache := pokecache.NewCache(time.Minute)
+	t.Cleanup(cache.Close)
+	return pokeapi.NewClient(server.BaseURL(), cache, pokeapi.WithHTTPClient(server.Client()))
+}
+
+func TestMapPagesThroughFakeAPI(t *testing.T) {
+	client := newFakeAPIClient(t)
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+	ctx := context.Background()
+
+	page, err := GetLocationAreas(ctx, client, &config, "forward")
+	if err != nil || page.Err != nil || len(page.Areas) != 20 {
+		t.Fatalf("expected a first page of 20 areas, got %d, %v, %v", len(page.Areas), err, page.Err)
+	}
+	page, err = GetLocationAreas(ctx, client, &config, "forward")
+	if err != nil || page.Err != nil || len(page.Areas) != 5 {
+		t.Fatalf("expected a second page of 5 areas, got %d, %v, %v", len(page.Areas), err, page.Err)
+	}
+	if config.Next != nil {
+		t.Errorf("expected the last page to have no next page")
+	}
+	page, err = GetLocationAreas(ctx, client, &config, "backward")
+	if err != nil || page.Err != nil || len(page.Areas) != 20 {
+		t.Fatalf("expected to go back to the first page, got %d, %v, %v", len(page.Areas), err, page.Err)
+	}
+	if config.Previous != nil {
+		t.Errorf("expected the first page to have no previous page")
+	}
+}
+
+func TestSyncFakeAPI(t *testing.T) {
+	client := newFakeAPIClient(t)
+	state := LoadSyncState("")
+
+	err := Sync(context.Background(), client, state, func(SyncProgress) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// map, explore and catch should now all work offline
+	client.SetOffline(true)
+	checkOffline(t, client)
+}
+
+func TestSyncedDataOutlivesExpiry(t *testing.T) {
+	// The TTLs the REPL uses by default
+	const listTTL, resourceTTL, staleTTL = time.Hour, 7 * 24 * time.Hour, 24 * time.Hour
+	server := fakepokeapi.NewServer()
+	t.Cleanup(server.Close)
+	dir := t.TempDir()
+	clock := pokecache.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newClient := func() (*pokeapi.Client, *pokecache.Cache) {
+		cache := pokecache.NewCache(time.Minute, pokecache.WithDiskDir(dir), pokecache.WithStaleTTL(staleTTL), pokecache.WithClock(clock))
+		t.Cleanup(cache.Close)
+		client := pokeapi.NewClient(server.BaseURL(), cache,
+			pokeapi.WithHTTPClient(server.Client()),
+			pokeapi.WithCacheTTLs(listTTL, resourceTTL),
+		)
+		return client, cache
+	}
+
+	client, _ := newClient()
+	err := Sync(context.Background(), client, LoadSyncState(""), func(SyncProgress) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Long after everything has expired, a fresh start offline still has it
+	// all. The first cache is never closed, as if the sync had been killed,
+	// so this also relies on each page having been flushed to disk.
+	clock.Advance(resourceTTL + staleTTL + listTTL)
+	client, cache := newClient()
+	client.SetOffline(true)
+	checkOffline(t, client)
+
+	// including once the reaper has dropped it all from memory
+	clock.Advance(time.Minute)
+	if !waitFor(t, func() bool { return cache.Stats().Entries == 0 }) {
+		t.Fatalf("expected the reaper to drop everything from memory")
+	}
+	checkOffline(t, client)
+}
+
+// waitFor polls cond until it holds, for checks on the cache's reaper
+// goroutine that can't be synchronised on directly.
+func waitFor(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// checkOffline checks that map, explore and catch all work from the cache.
+func checkOffline(t *testing.T, client *pokeapi.Client) {
+	t.Helper()
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+	// map, map, then mapb back to the first page through its previous link
+	for _, step := range []struct {
+		direction string
+		want      int
+	}{{"forward", 20}, {"forward", 5}, {"backward", 20}} {
+		page, err := GetLocationAreas(context.Background(), client, &config, step.direction)
+		if err != nil || page.Err != nil || len(page.Areas) != step.want {
+			t.Fatalf("expected a cached page of %d areas going %s, got %d, %v, %v", step.want, step.direction, len(page.Areas), err, page.Err)
+		}
+	}
+	for _, name := range []string{"canalave-city-area", "great-marsh-area-2"} {
+		area, err := client.GetLocationArea(context.Background(), name)
+		if err != nil {
+			t.Fatalf("expected %s to be cached, got %v", name, err)
+		}
+		for _, encounter := range area.PokemonEncounters {
+			_, err := client.GetPokemon(context.Background(), encounter.Pokemon.Name)
+			if err != nil {
+				t.Errorf("expected %s to be cached, got %v", encounter.Pokemon.Name, err)
+			}
+		}
+	}
+}
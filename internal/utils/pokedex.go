@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/atomicfile"
+)
+
+// pokedexSaveVersion is bumped whenever the layout of the save file changes.
+const pokedexSaveVersion = 1
+
+// pokedexSave is the on-disk representation of a Pokedex.
+type pokedexSave struct {
+	Version int                `json:"version"`
+	Pokemon map[string]Pokemon `json:"pokemon"`
+}
+
+// DefaultPokedexPath returns the save file location under the user's config dir.
+func DefaultPokedexPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding user config dir: %w", err)
+	}
+	return filepath.Join(configDir, "pokedex-go", "pokedex.json"), nil
+}
+
+// Save writes the pokedex to path as JSON. The file is written to a temporary
+// file first and renamed into place so a crash never leaves a half-written save.
+func (p *Pokedex) Save(path string) error {
+	save := pokedexSave{Version: pokedexSaveVersion, Pokemon: p.Pokemon}
+	data, err := json.MarshalIndent(save, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling pokedex: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("error creating pokedex dir: %w", err)
+	}
+
+	err = atomicfile.Write(path, data)
+	if err != nil {
+		return fmt.Errorf("error saving pokedex: %w", err)
+	}
+	return nil
+}
+
+// Load replaces the contents of the pokedex with the save file at path.
+// A missing file is not an error; the pokedex is simply left empty.
+func (p *Pokedex) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if p.Pokemon == nil {
+			p.Pokemon = make(map[string]Pokemon)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading pokedex: %w", err)
+	}
+
+	var save pokedexSave
+	err = json.Unmarshal(data, &save)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling pokedex: %w", err)
+	}
+	if save.Version != pokedexSaveVersion {
+		return fmt.Errorf("unsupported pokedex save version %d", save.Version)
+	}
+
+	if save.Pokemon == nil {
+		save.Pokemon = make(map[string]Pokemon)
+	}
+	p.Pokemon = save.Pokemon
+	return nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPokedexSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "pokedex.json")
+	dex := Pokedex{Pokemon: make(map[string]Pokemon), Path: path}
+
+	err := AddToDex(&Pokemon{ID: 25, Name: "pikachu"}, &dex)
+	if err != nil {
+		t.Fatalf("unexpected error adding to dex: %v", err)
+	}
+
+	loaded := Pokedex{}
+	err = loaded.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading dex: %v", err)
+	}
+	if loaded.Pokemon["pikachu"].ID != 25 {
+		t.Errorf("expected to find pikachu in loaded dex")
+	}
+}
+
+func TestPokedexLoadMissingFile(t *testing.T) {
+	dex := Pokedex{}
+	err := dex.Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("expected no error for missing save, got %v", err)
+	}
+	if dex.Pokemon == nil || len(dex.Pokemon) != 0 {
+		t.Errorf("expected an empty pokedex")
+	}
+}
+
+func TestPokedexLoadBadVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pokedex.json")
+	err := os.WriteFile(path, []byte(`{"version": 99, "pokemon": {}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dex := Pokedex{}
+	err = dex.Load(path)
+	if err == nil {
+		t.Errorf("expected error for unsupported save version")
+	}
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/atomicfile"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+)
+
+// syncPageSize is how many pokemon Sync asks for per list page, so walking
+// the endpoint takes fewer list requests than the API's default of 20.
+// Location areas use the default pages, since those are the ones map shows.
+const syncPageSize = 100
+
+// SyncState records how far Sync has got through each endpoint, so an
+// interrupted sync picks up where it stopped.
+type SyncState struct {
+	Cursors map[string]*SyncCursor `json:"cursors"`
+	// Path is where the state is saved after every page. Empty disables saving.
+	Path string `json:"-"`
+}
+
+// SyncCursor is the position of a sync within one endpoint.
+type SyncCursor struct {
+	// Next is the list page to fetch next; empty means the first page.
+	Next string `json:"next"`
+	// Done is how many resources have been fetched, out of Total.
+	Done     int  `json:"done"`
+	Total    int  `json:"total"`
+	Complete bool `json:"complete"`
+}
+
+// SyncProgress is reported by Sync after each resource it fetches.
+type SyncProgress struct {
+	Resource string
+	Done     int
+	Total    int
+}
+
+// syncResource is an endpoint that Sync walks.
+type syncResource struct {
+	name  string
+	first string
+	list  func(ctx context.Context, pageURL string) (NamedAPIResourceList, error)
+	fetch func(ctx context.Context, name string) error
+}
+
+func syncResources(client *pokeapi.Client) []syncResource {
+	return []syncResource{
+		{
+			name:  "location-area",
+			first: client.LocationAreasURL(),
+			list:  client.ListLocationAreas,
+			fetch: func(ctx context.Context, name string) error {
+				_, err := client.GetLocationArea(ctx, name)
+				return err
+			},
+		},
+		{
+			name:  "pokemon",
+			first: fmt.Sprintf("%s?limit=%d", client.PokemonURL(), syncPageSize),
+			list:  client.ListPokemon,
+			fetch: func(ctx context.Context, name string) error {
+				_, err := client.GetPokemon(ctx, name)
+				return err
+			},
+		},
+	}
+}
+
+// LoadSyncState reads the sync state saved at path. A missing or unreadable
+// file just means starting from the beginning.
+func LoadSyncState(path string) *SyncState {
+	state := &SyncState{Path: path}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		json.Unmarshal(data, state)
+	}
+	if state.Cursors == nil {
+		state.Cursors = make(map[string]*SyncCursor)
+	}
+	return state
+}
+
+// Save writes the state to its Path, if it has one.
+func (s *SyncState) Save() error {
+	if s.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshaling sync state: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(s.Path), 0o755)
+	if err != nil {
+		return fmt.Errorf("error creating sync state dir: %w", err)
+	}
+	// An interrupted write must not lose track of a long sync
+	err = atomicfile.Write(s.Path, data)
+	if err != nil {
+		return fmt.Errorf("error saving sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *SyncState) cursor(resource string) *SyncCursor {
+	cursor, exists := s.Cursors[resource]
+	if !exists {
+		cursor = &SyncCursor{}
+		s.Cursors[resource] = cursor
+	}
+	return cursor
+}
+
+// Sync walks the location-area and pokemon list endpoints page by page and
+// fetches every resource on them, filling the client's cache so the Pokedex
+// can later be used offline. Progress is saved to state after each page, so
+// calling Sync again after an error or cancellation resumes from that page.
+// Once everything has been synced, the next call starts over to refresh it.
+func Sync(ctx context.Context, client *pokeapi.Client, state *SyncState, progress func(SyncProgress)) error {
+	resources := syncResources(client)
+	allComplete := true
+	for _, resource := range resources {
+		allComplete = allComplete && state.cursor(resource.name).Complete
+	}
+	if allComplete {
+		state.Cursors = make(map[string]*SyncCursor)
+	}
+
+	for _, resource := range resources {
+		err := syncEndpoint(ctx, client, resource, state, progress)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncEndpoint(ctx context.Context, client *pokeapi.Client, resource syncResource, state *SyncState, progress func(SyncProgress)) error {
+	cursor := state.cursor(resource.name)
+	for !cursor.Complete {
+		pageURL := cursor.Next
+		if pageURL == "" {
+			pageURL = resource.first
+		}
+		page, err := resource.list(ctx, pageURL)
+		if err != nil {
+			return fmt.Errorf("error syncing %s: %w", resource.name, err)
+		}
+		cursor.Total = page.Count
+
+		done := cursor.Done
+		err = fetchAll(ctx, client.MaxConcurrency(), page.Results, resource.fetch, func() {
+			done++
+			progress(SyncProgress{Resource: resource.name, Done: done, Total: cursor.Total})
+		})
+		if err != nil {
+			return fmt.Errorf("error syncing %s: %w", resource.name, err)
+		}
+
+		// Only move on once the whole page is in the cache, and the cache
+		// will find it there after a restart
+		err = client.FlushCache()
+		if err != nil {
+			return fmt.Errorf("error syncing %s: %w", resource.name, err)
+		}
+		cursor.Done = done
+		if page.Next == nil {
+			cursor.Next = ""
+			cursor.Complete = true
+		} else {
+			cursor.Next = *page.Next
+		}
+		err = state.Save()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchAll fetches every resource with up to workers requests in flight,
+// calling fetched after each success. It returns the first error, once the
+// requests already under way have finished.
+func fetchAll(ctx context.Context, workers int, resources []NamedAPIResource, fetch func(context.Context, string) error, fetched func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	nameCh := make(chan string)
+	errCh := make(chan error, len(resources))
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(resources)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range nameCh {
+				errCh <- fetch(ctx, name)
+			}
+		}()
+	}
+	go func() {
+		defer close(nameCh)
+		for _, resource := range resources {
+			select {
+			case nameCh <- resource.Name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(errCh)
+	}()
+
+	var firstErr error
+	for err := range errCh {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				// No point starting more requests for a page we'll redo
+				cancel()
+			}
+			continue
+		}
+		fetched()
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+)
+
+// newSyncServer serves paginated lists of count location areas and pokemon
+// and counts requests by path and query. fail reports whether a request
+// should get a 500 instead.
+func newSyncServer(t *testing.T, count int, fail func(r *http.Request) bool) (*httptest.Server, func(string) int) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.RequestURI()]++
+		mu.Unlock()
+		if fail(r) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) == 2 {
+			fmt.Fprintf(w, `{"name": %q}`, parts[1])
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = 20
+		}
+		list := NamedAPIResourceList{Count: count}
+		for i := offset; i < min(offset+limit, count); i++ {
+			list.Results = append(list.Results, NamedAPIResource{Name: fmt.Sprintf("%s-%d", parts[0], i)})
+		}
+		if offset+limit < count {
+			next := fmt.Sprintf("%s%s?offset=%d&limit=%d", server.URL, r.URL.Path, offset+limit, limit)
+			list.Next = &next
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+	return server, func(uri string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[uri]
+	}
+}
+
+func TestSyncResumes(t *testing.T) {
+	// Three pages of pokemon; the first attempt at the second page fails
+	var failed bool
+	var mu sync.Mutex
+	server, hits := newSyncServer(t, 2*syncPageSize+1, func(r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/pokemon/pokemon-150/" && !failed {
+			failed = true
+			return true
+		}
+		return false
+	})
+	cache := pokecache.NewCache(time.Minute)
+	defer cache.Close()
+	client := pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()))
+	state := LoadSyncState(filepath.Join(t.TempDir(), "sync.json"))
+
+	var last SyncProgress
+	progress := func(p SyncProgress) { last = p }
+	err := Sync(context.Background(), client, state, progress)
+	if err == nil {
+		t.Fatalf("expected the failed request to stop the sync")
+	}
+	if !state.Cursors["location-area"].Complete {
+		t.Errorf("expected location areas to have been synced")
+	}
+	if done := state.Cursors["pokemon"].Done; done != syncPageSize {
+		t.Errorf("expected to stop after the first page of pokemon, got %d done", done)
+	}
+
+	// The state is renamed into place, leaving nothing else behind
+	files, _ := os.ReadDir(filepath.Dir(state.Path))
+	if len(files) != 1 || files[0].Name() != "sync.json" {
+		t.Errorf("expected only the saved state, got %v", files)
+	}
+
+	// A fresh run picks the saved state up from disk
+	state = LoadSyncState(state.Path)
+	err = Sync(context.Background(), client, state, progress)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !state.Cursors["pokemon"].Complete {
+		t.Errorf("expected pokemon to have been synced")
+	}
+	if last.Resource != "pokemon" || last.Done != 2*syncPageSize+1 || last.Total != 2*syncPageSize+1 {
+		t.Errorf("unexpected final progress: %+v", last)
+	}
+	if n := hits(fmt.Sprintf("/pokemon/?limit=%d", syncPageSize)); n != 1 {
+		t.Errorf("expected the first page not to be fetched again, got %d requests", n)
+	}
+	if n := hits("/pokemon/pokemon-0/"); n != 1 {
+		t.Errorf("expected synced pokemon not to be fetched again, got %d requests", n)
+	}
+
+	// Everything is now cached, so it works offline
+	client.SetOffline(true)
+	_, err = client.GetPokemon(context.Background(), fmt.Sprintf("pokemon-%d", 2*syncPageSize))
+	if err != nil {
+		t.Errorf("expected synced pokemon to be available offline, got %v", err)
+	}
+}
+
+func TestSyncCancel(t *testing.T) {
+	server, _ := newSyncServer(t, syncPageSize, func(r *http.Request) bool { return false })
+	cache := pokecache.NewCache(time.Minute)
+	defer cache.Close()
+	client := pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()))
+	state := LoadSyncState("")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Sync(ctx, client, state, func(p SyncProgress) {
+		if p.Done == 10 {
+			cancel()
+		}
+	})
+	if ctx.Err() == nil || err == nil {
+		t.Fatalf("expected the sync to be cancelled, got %v", err)
+	}
+	if cursor := state.Cursors["location-area"]; cursor.Done != 0 || cursor.Complete {
+		t.Errorf("expected the unfinished page not to be recorded, got %+v", cursor)
+	}
+}
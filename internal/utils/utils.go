@@ -1,16 +1,14 @@
 package utils
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"math"
 	"math/rand"
-	"net/http"
 	"sync"
 
-	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
 )
 
 type UrlConfig struct {
@@ -20,152 +18,54 @@ type UrlConfig struct {
 
 type Pokedex struct {
 	Pokemon map[string]Pokemon
-}
-
-// LocationArea represents the structure of a single location area from PokeAPI.
-// JSON tags (`json:"name"`) are used to map JSON keys to Go struct fields.
-type LocationArea struct {
-	ID                   int                   `json:"id"`
-	Name                 string                `json:"name"`
-	GameIndex            int                   `json:"game_index"`
-	EncounterMethodRates []EncounterMethodRate `json:"encounter_method_rates"`
-	Location             NamedAPIResource      `json:"location"`
-	Names                []Name                `json:"names"`
-	PokemonEncounters    []PokemonEncounter    `json:"pokemon_encounters"`
-}
-
-type EncounterMethodRate struct {
-	EncounterMethod NamedAPIResource `json:"encounter_method"`
-	VersionDetails  []VersionDetail  `json:"version_details"`
-}
-
-type VersionDetail struct {
-	Rate    int              `json:"rate"`
-	Version NamedAPIResource `json:"version"`
-}
-
-type PokemonEncounter struct {
-	Pokemon        NamedAPIResource         `json:"pokemon"`
-	VersionDetails []VersionEncounterDetail `json:"version_details"`
-}
-
-type VersionEncounterDetail struct {
-	EncounterDetails []EncounterDetail `json:"encounter_details"`
-	MaxChance        int               `json:"max_chance"`
-	Version          NamedAPIResource  `json:"version"`
-}
-
-type EncounterDetail struct {
-	Chance          int                `json:"chance"`
-	ConditionValues []NamedAPIResource `json:"condition_values"`
-	MaxLevel        int                `json:"max_level"`
-	Method          NamedAPIResource   `json:"method"`
-	MinLevel        int                `json:"min_level"`
-}
-
-type NamedAPIResource struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
-}
-
-// Name represents a localized name for a resource, typically found in 'names' arrays.
-type Name struct {
-	Name     string           `json:"name"`
-	Language NamedAPIResource `json:"language"`
-}
-
-type NamedAPIResourceList struct {
-	Count    int                `json:"count"`
-	Next     *string            `json:"next"`     // Pointer to string as it can be null
-	Previous *string            `json:"previous"` // Pointer to string as it can be null
-	Results  []NamedAPIResource `json:"results"`
-}
-
-// Pokemon represents the main structure of a single Pokemon from PokeAPI.
-type Pokemon struct {
-	ID             int                `json:"id"`
-	Name           string             `json:"name"`
-	BaseExperience int                `json:"base_experience"`
-	Height         int                `json:"height"`
-	IsDefault      bool               `json:"is_default"`
-	Order          int                `json:"order"`
-	Weight         int                `json:"weight"`
-	Abilities      []PokemonAbility   `json:"abilities"`
-	Forms          []NamedAPIResource `json:"forms"`
-	GameIndices    []VersionGameIndex `json:"game_indices"`
-	HeldItems      []PokemonHeldItem  `json:"held_items"`
-	Moves          []PokemonMove      `json:"moves"`
-	Species        NamedAPIResource   `json:"species"`
-	Sprites        PokemonSprites     `json:"sprites"`
-	Stats          []PokemonStat      `json:"stats"`
-	Types          []PokemonType      `json:"types"`
-}
-
-// PokemonAbility represents an ability of a Pokemon.
-type PokemonAbility struct {
-	IsHidden bool             `json:"is_hidden"`
-	Slot     int              `json:"slot"`
-	Ability  NamedAPIResource `json:"ability"`
-}
-
-// VersionGameIndex represents a game index for a Pokemon.
-type VersionGameIndex struct {
-	GameIndex int              `json:"game_index"`
-	Version   NamedAPIResource `json:"version"`
-}
-
-// PokemonHeldItem represents an item held by a Pokemon.
-type PokemonHeldItem struct {
-	Item           NamedAPIResource         `json:"item"`
-	VersionDetails []PokemonHeldItemVersion `json:"version_details"`
-}
-
-// PokemonHeldItemVersion represents version details for a held item.
-type PokemonHeldItemVersion struct {
-	Version NamedAPIResource `json:"version"`
-	Rarity  int              `json:"rarity"`
-}
-
-// PokemonMove represents a move a Pokemon can learn.
-type PokemonMove struct {
-	Move                NamedAPIResource     `json:"move"`
-	VersionGroupDetails []PokemonMoveVersion `json:"version_group_details"`
-}
-
-// PokemonMoveVersion represents version details for a Pokemon move.
-type PokemonMoveVersion struct {
-	LevelLearnedAt  int              `json:"level_learned_at"`
-	MoveLearnMethod NamedAPIResource `json:"move_learn_method"`
-	VersionGroup    NamedAPIResource `json:"version_group"`
-}
-
-// PokemonSprites contains URLs for various sprites of a Pokemon.
-type PokemonSprites struct {
-	BackDefault      *string `json:"back_default"`
-	BackFemale       *string `json:"back_female"`
-	BackShiny        *string `json:"back_shiny"`
-	BackShinyFemale  *string `json:"back_shiny_female"`
-	FrontDefault     *string `json:"front_default"`
-	FrontFemale      *string `json:"front_female"`
-	FrontShiny       *string `json:"front_shiny"`
-	FrontShinyFemale *string `json:"front_shiny_female"`
-	// Add other sprite fields if needed, e.g., "other", "versions"
-}
-
-// PokemonStat represents a stat of a Pokemon.
-type PokemonStat struct {
-	BaseStat int              `json:"base_stat"`
-	Effort   int              `json:"effort"`
-	Stat     NamedAPIResource `json:"stat"`
-}
-
-// PokemonType represents a type of a Pokemon.
-type PokemonType struct {
-	Slot int              `json:"slot"`
-	Type NamedAPIResource `json:"type"`
-}
+	// Path is where the pokedex is saved after every catch. Empty disables saving.
+	Path string
+}
+
+// The PokeAPI resource types live in the pokeapi package; these aliases keep
+// existing callers of utils working.
+type (
+	LocationArea           = pokeapi.LocationArea
+	EncounterMethodRate    = pokeapi.EncounterMethodRate
+	VersionDetail          = pokeapi.VersionDetail
+	PokemonEncounter       = pokeapi.PokemonEncounter
+	VersionEncounterDetail = pokeapi.VersionEncounterDetail
+	EncounterDetail        = pokeapi.EncounterDetail
+	NamedAPIResource       = pokeapi.NamedAPIResource
+	Name                   = pokeapi.Name
+	NamedAPIResourceList   = pokeapi.NamedAPIResourceList
+	Pokemon                = pokeapi.Pokemon
+	PokemonAbility         = pokeapi.PokemonAbility
+	VersionGameIndex       = pokeapi.VersionGameIndex
+	PokemonHeldItem        = pokeapi.PokemonHeldItem
+	PokemonHeldItemVersion = pokeapi.PokemonHeldItemVersion
+	PokemonMove            = pokeapi.PokemonMove
+	PokemonMoveVersion     = pokeapi.PokemonMoveVersion
+	PokemonSprites         = pokeapi.PokemonSprites
+	PokemonStat            = pokeapi.PokemonStat
+	PokemonType            = pokeapi.PokemonType
+)
 
-func GetLocationAreas(config *UrlConfig, direction string, cache *pokecache.Cache) ([]LocationArea, error) {
+// LocationAreasResult is a page of location areas, kept in the order the
+// API listed them.
+type LocationAreasResult struct {
+	// Names lists every area on the page.
+	Names []string
+	// Areas holds the details of each area that could be fetched.
+	Areas []LocationArea
+	// Failed holds, by name, why the details of the other areas couldn't be fetched.
+	Failed map[string]error
+	// Err joins the errors in Failed, and is nil if every area was fetched.
+	Err error
+}
+
+// GetLocationAreas fetches the next or previous page of location areas, as
+// given by direction, along with the details of every area on it. An error
+// is returned only if the page itself can't be fetched or ctx is cancelled;
+// areas whose details can't be fetched are reported in the result instead.
+// config is only moved on to the new page when the page is returned, so a
+// cancelled fetch leaves it where it was.
+func GetLocationAreas(ctx context.Context, client *pokeapi.Client, config *UrlConfig, direction string) (LocationAreasResult, error) {
 	// Define the API endpoint URL for listing location areas (default limit is 20)
 	var listAPIURL string
 	if direction == "forward" {
@@ -174,223 +74,84 @@ func GetLocationAreas(config *UrlConfig, direction string, cache *pokecache.Cach
 		listAPIURL = *config.Previous
 	}
 
-	fmt.Printf("Fetching list of location areas from: %s\n", listAPIURL)
-
 	// --- Step 1: Fetch the list of NamedAPIResources ---
-	// If URL is in Cache, skip Fetch
-	data, exists := cache.Get(listAPIURL)
-	var resourceList NamedAPIResourceList
-	if exists {
-		err := json.Unmarshal(data, &resourceList)
-		if err != nil {
-			fmt.Printf("Error unmarshaling list JSON: %v\n", err)
-			fmt.Printf("Response body: %s\n", string(data))
-			return nil, err
-		}
-	} else {
-		resp, err := http.Get(listAPIURL)
-		if err != nil {
-			fmt.Printf("Error making HTTP request for list: %v\n", err)
-			return nil, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			fmt.Printf("Received non-OK HTTP status for list: %s\n", resp.Status)
-			return nil, errors.New("non-OK HTTP status")
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Printf("Error reading list response body: %v\n", err)
-			return nil, err
-		}
-
-		// Adds to Cache for later use.
-		cache.Add(listAPIURL, body)
-
-		err = json.Unmarshal(body, &resourceList)
-		if err != nil {
-			fmt.Printf("Error unmarshaling list JSON: %v\n", err)
-			fmt.Printf("Response body: %s\n", string(body))
-			return nil, err
-		}
+	resourceList, err := client.ListLocationAreas(ctx, listAPIURL)
+	if err != nil {
+		return LocationAreasResult{}, fmt.Errorf("error fetching list of location areas: %w", err)
 	}
 
-	// --- Update urlConfig with new next and previous URLs ---
-	config.Next = resourceList.Next
-	config.Previous = resourceList.Previous
-
-	fmt.Printf("Found %d location areas in the list (showing up to 20 by default).\n", len(resourceList.Results))
-
 	// --- Step 2: Fetch details for each location area concurrently ---
-	// Use a WaitGroup to wait for all goroutines to complete
-	var wg sync.WaitGroup
-	// Use a channel to collect results safely from goroutines
-	locationAreaCh := make(chan LocationArea, len(resourceList.Results))
-	// Use a channel to collect errors from goroutines
-	errorCh := make(chan error, len(resourceList.Results))
-
-	for _, resource := range resourceList.Results {
-		wg.Add(1) // Increment the counter for each goroutine
-		go func(url string) {
-			defer wg.Done() // Decrement the counter when the goroutine finishes
-
-			//fmt.Printf("  Fetching details for: %s\n", url)
-			detailResp, detailErr := http.Get(url)
-			if detailErr != nil {
-				errorCh <- fmt.Errorf("error making HTTP request for %s: %w", url, detailErr)
-				return
-			}
-			defer detailResp.Body.Close()
-
-			if detailResp.StatusCode != http.StatusOK {
-				errorCh <- fmt.Errorf("received non-OK HTTP status for %s: %s", url, detailResp.Status)
-				return
-			}
-
-			detailBody, detailErr := io.ReadAll(detailResp.Body)
-			if detailErr != nil {
-				errorCh <- fmt.Errorf("error reading detail response body for %s: %w", url, detailErr)
-				return
-			}
+	// Each worker writes to its own slot, so the results keep the API's order
+	count := len(resourceList.Results)
+	details := make([]LocationArea, count)
+	detailErrs := make([]error, count)
 
-			var locationArea LocationArea
-			detailErr = json.Unmarshal(detailBody, &locationArea)
-			if detailErr != nil {
-				errorCh <- fmt.Errorf("error unmarshaling detail JSON for %s: %w\nBody: %s", url, detailErr, string(detailBody))
-				return
+	// A fixed pool of workers keeps at most MaxConcurrency requests in flight
+	indexCh := make(chan int)
+	var wg sync.WaitGroup
+	workers := min(client.MaxConcurrency(), count)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexCh {
+				// Fetch by name so the cache key matches the one ExploreArea uses
+				details[index], detailErrs[index] = client.GetLocationArea(ctx, resourceList.Results[index].Name)
 			}
-			locationAreaCh <- locationArea // Send the successfully unmarshaled struct to the channel
-		}(resource.URL) // Pass the URL to the goroutine
+		}()
 	}
-
-	// Close the channels once all goroutines are done
-	go func() {
-		wg.Wait()
-		close(locationAreaCh)
-		close(errorCh)
-	}()
-
-	// Collect results and errors
-	var allLocationAreas []LocationArea
-	var encounteredErrors []error
-
-	for {
-		select {
-		case la, ok := <-locationAreaCh:
-			if !ok { // Channel closed and empty
-				locationAreaCh = nil // Mark as nil to stop selecting from it
-				break
-			}
-			allLocationAreas = append(allLocationAreas, la)
-		case err, ok := <-errorCh:
-			if !ok { // Channel closed and empty
-				errorCh = nil // Mark as nil to stop selecting from it
-				break
-			}
-			encounteredErrors = append(encounteredErrors, err)
-		}
-
-		// Exit the loop if both channels are closed and empty
-		if locationAreaCh == nil && errorCh == nil {
-			break
-		}
+	for i := range resourceList.Results {
+		indexCh <- i
 	}
+	close(indexCh)
+	wg.Wait()
 
-	fmt.Println("\n--- Summary of Fetched Location Areas ---")
-	fmt.Printf("Successfully fetched details for %d location areas.\n", len(allLocationAreas))
-	fmt.Printf("Encountered %d errors during detail fetching.\n\n", len(encounteredErrors))
+	// A cancelled page isn't a page of failed areas; leave the cursor where
+	// it was so the same page is fetched next time.
+	err = ctx.Err()
+	if err != nil {
+		return LocationAreasResult{}, fmt.Errorf("error fetching location areas: %w", err)
+	}
 
-	if len(encounteredErrors) > 0 {
-		fmt.Println("\n--- Details of Errors ---")
-		for _, e := range encounteredErrors {
-			fmt.Printf("- %v\n\n", e)
+	result := LocationAreasResult{Failed: make(map[string]error)}
+	var errs []error
+	for i, resource := range resourceList.Results {
+		result.Names = append(result.Names, resource.Name)
+		if detailErrs[i] != nil {
+			result.Failed[resource.Name] = detailErrs[i]
+			errs = append(errs, fmt.Errorf("error fetching location area %s: %w", resource.Name, detailErrs[i]))
+			continue
 		}
+		result.Areas = append(result.Areas, details[i])
 	}
+	result.Err = errors.Join(errs...)
 
-	return allLocationAreas, nil
+	// --- Update urlConfig with new next and previous URLs ---
+	config.Next = resourceList.Next
+	config.Previous = resourceList.Previous
+	return result, nil
 }
 
-func ExploreArea(location string, cache *pokecache.Cache) ([]PokemonEncounter, error) {
-	apiURL := "https://pokeapi.co/api/v2/location-area/" + location + "/"
-	fmt.Printf("Exploring area: %s\n", location)
-
-	var locationAreaDetails LocationArea
-	var err error
-	var body []byte
+// ExploreArea returns the pokemon that can be encountered at location.
+func ExploreArea(ctx context.Context, client *pokeapi.Client, location string) ([]PokemonEncounter, error) {
 
 	// --- Step 1: Fetch the specific LocationArea details ---
-	// If URL is in Cache, skip Fetch
-	data, exists := cache.Get(apiURL)
-	if exists {
-		body = data
-	} else {
-		resp, httpErr := http.Get(apiURL)
-		if httpErr != nil {
-			return nil, fmt.Errorf("error making HTTP request for location area: %w", httpErr)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("received non-OK HTTP status for location area: %s", resp.Status)
-		}
-
-		body, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error reading location area response body: %w", err)
-		}
-
-		cache.Add(apiURL, body)
-	}
-
-	// Unmarshal the body (either from cache or HTTP response) into the LocationArea struct
-	err = json.Unmarshal(body, &locationAreaDetails)
+	locationAreaDetails, err := client.GetLocationArea(ctx, location)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling location area JSON: %w\nResponse body: %s", err, string(body))
+		return nil, err
 	}
-	fmt.Printf("Successfully unmarshaled details for location area: %s (ID: %d)\n", locationAreaDetails.Name, locationAreaDetails.ID)
 
 	// Directly return the PokemonEncounters slice from the fetched LocationArea
-	fmt.Printf("Found %d pokemon encounters in the area.\n", len(locationAreaDetails.PokemonEncounters))
 	return locationAreaDetails.PokemonEncounters, nil
 }
 
-func CatchPokemon(pokemonName string, cache *pokecache.Cache) (*Pokemon, bool, error) {
-
-	apiURL := "https://pokeapi.co/api/v2/pokemon/" + pokemonName + "/"
-
-	var pokemon Pokemon
-	var err error
-	var body []byte
+// CatchPokemon fetches pokemonName and throws a Pokeball at it, reporting
+// whether it was caught.
+func CatchPokemon(ctx context.Context, client *pokeapi.Client, pokemonName string) (*Pokemon, bool, error) {
 
 	// --- Step 1: Fetch the Pokemon ---
-	// If URL is in Cache, skip Fetch
-	data, exists := cache.Get(apiURL)
-	if exists {
-		body = data
-	} else {
-		resp, httpErr := http.Get(apiURL)
-		if httpErr != nil {
-			return nil, false, fmt.Errorf("error making HTTP request for %s: %w", pokemonName, httpErr)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, false, fmt.Errorf("received non-OK HTTP status for %s: %s", pokemonName, resp.Status)
-		}
-
-		body, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, false, fmt.Errorf("error reading response body for %s: %w", pokemonName, err)
-		}
-		cache.Add(apiURL, body)
-	}
-
-	err = json.Unmarshal(body, &pokemon)
+	pokemon, err := client.GetPokemon(ctx, pokemonName)
 	if err != nil {
-		fmt.Printf("Error unmarshaling list JSON: %v\n", err)
-		fmt.Printf("Response body: %s\n", string(body))
 		return nil, false, err
 	}
 
@@ -398,11 +159,8 @@ func CatchPokemon(pokemonName string, cache *pokecache.Cache) (*Pokemon, bool, e
 
 	// A simple catch rate: higher base_experience makes it harder to catch
 	// Let's say, catch if random number (0-100) is greater than base_experience / 2
-	catchDifficulty := catchChance(pokemon.BaseExperience, 635, 0.02, 5.0)
+	catchDifficulty := CatchChance(pokemon)
 	roll := rand.Intn(101) // Random number between 0 and 100
-	fmt.Printf("Throwing a Pokeball at %s...\n", pokemon.Name)
-	fmt.Printf("Catch difficulty for %s (Base Exp: %d): %d%%\n", pokemon.Name, pokemon.BaseExperience, catchDifficulty)
-	fmt.Printf("Your roll: %d\n", roll)
 
 	if roll > (100 - catchDifficulty) {
 		return &pokemon, true, nil
@@ -411,6 +169,11 @@ func CatchPokemon(pokemonName string, cache *pokecache.Cache) (*Pokemon, bool, e
 	}
 }
 
+// CatchChance returns the percentage chance of catching pokemon.
+func CatchChance(pokemon Pokemon) int {
+	return catchChance(pokemon.BaseExperience, 635, 0.02, 5.0)
+}
+
 func catchChance(baseExp int, maxExp int, minChance float64, k float64) int {
 	// Convert inputs to float64 for math.Exp
 	normalizedExp := float64(baseExp) / float64(maxExp)
@@ -420,13 +183,16 @@ func catchChance(baseExp int, maxExp int, minChance float64, k float64) int {
 	return int(math.Round(chance * 100))
 }
 
-func AddToDex(pokemon *Pokemon, pokedex *Pokedex) {
+func AddToDex(pokemon *Pokemon, pokedex *Pokedex) error {
 	_, exists := pokedex.Pokemon[pokemon.Name]
 	if exists {
-		return
+		return nil
 	}
 	pokedex.Pokemon[pokemon.Name] = *pokemon
-	return
+	if pokedex.Path == "" {
+		return nil
+	}
+	return pokedex.Save(pokedex.Path)
 }
 
 func InspectPokemon(pokemon string, pokedex *Pokedex) (*Pokemon, error) {
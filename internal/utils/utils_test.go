@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/curtisbraxdale/pokedex-go/internal/pokeapi"
+	"github.com/curtisbraxdale/pokedex-go/internal/pokecache"
+)
+
+func TestGetLocationAreasWarmsExploreCache(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/location-area/" {
+			// The list links to areas by ID, as the real PokeAPI does
+			fmt.Fprintf(w, `{"count": 1, "results": [{"name": "canalave-city-area", "url": "%s/location-area/1/"}]}`, server.URL)
+			return
+		}
+		fmt.Fprint(w, `{"id": 1, "name": "canalave-city-area", "pokemon_encounters": [{"pokemon": {"name": "tentacool"}}]}`)
+	}))
+	defer server.Close()
+
+	cache := pokecache.NewCache(time.Minute)
+	defer cache.Close()
+	client := pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()))
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+
+	page, err := GetLocationAreas(context.Background(), client, &config, "forward")
+	if err != nil || page.Err != nil {
+		t.Fatalf("unexpected error: %v, %v", err, page.Err)
+	}
+	if len(page.Areas) != 1 {
+		t.Fatalf("expected 1 area, got %d", len(page.Areas))
+	}
+
+	encounters, err := ExploreArea(context.Background(), client, "canalave-city-area")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(encounters) != 1 || encounters[0].Pokemon.Name != "tentacool" {
+		t.Errorf("unexpected encounters: %+v", encounters)
+	}
+
+	if hits["/location-area/1/"] != 0 {
+		t.Errorf("expected details to be fetched by name, not by ID")
+	}
+	for path, count := range hits {
+		if strings.HasPrefix(path, "/location-area/") && count != 1 {
+			t.Errorf("expected %s to be fetched once, got %d", path, count)
+		}
+	}
+}
+
+// newErrorClient returns a client for a server giving the responses the
+// real PokeAPI doesn't, so they can't be recorded: areas missing from the
+// list that names them, and malformed bodies.
+func newErrorClient(t *testing.T) *pokeapi.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/location-area/":
+			fmt.Fprint(w, `{"count": 3, "results": [{"name": "canalave-city-area"}, {"name": "eterna-city-area"}, {"name": "pastoria-city-area"}]}`)
+		case "/location-area/canalave-city-area/", "/location-area/pastoria-city-area/":
+			name := strings.Split(r.URL.Path, "/")[2]
+			fmt.Fprintf(w, `{"name": %q, "pokemon_encounters": [{"pokemon": {"name": "tentacool"}}]}`, name)
+		case "/location-area/broken-area/", "/pokemon/glitch/":
+			fmt.Fprint(w, `{"name": "glitch", "pokemon_encounters": [{`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	cache := pokecache.NewCache(time.Minute)
+	t.Cleanup(cache.Close)
+	return pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()))
+}
+
+func TestGetLocationAreasSkipsMissingAreas(t *testing.T) {
+	client := newErrorClient(t)
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+
+	page, err := GetLocationAreas(context.Background(), client, &config, "forward")
+	if err != nil {
+		t.Fatalf("expected the page itself to be fetched, got %v", err)
+	}
+	if !errors.Is(page.Err, pokeapi.ErrNotFound) || !strings.Contains(page.Err.Error(), "eterna-city-area") {
+		t.Errorf("expected the missing area to be reported, got %v", page.Err)
+	}
+	if len(page.Failed) != 1 || !errors.Is(page.Failed["eterna-city-area"], pokeapi.ErrNotFound) {
+		t.Errorf("expected only eterna-city-area to have failed, got %v", page.Failed)
+	}
+	if names := strings.Join(page.Names, ","); names != "canalave-city-area,eterna-city-area,pastoria-city-area" {
+		t.Errorf("expected every area on the page to be listed, got %s", names)
+	}
+	if names := strings.Join(areaNames(page.Areas), ","); names != "canalave-city-area,pastoria-city-area" {
+		t.Errorf("expected the areas that were found, got %s", names)
+	}
+}
+
+func TestExploreAreaErrors(t *testing.T) {
+	client := newErrorClient(t)
+	_, err := ExploreArea(context.Background(), client, "nowhere")
+	var statusErr *pokeapi.HTTPStatusError
+	if !errors.Is(err, pokeapi.ErrNotFound) || !errors.As(err, &statusErr) || statusErr.StatusCode != 404 {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+
+	_, err = ExploreArea(context.Background(), client, "broken-area")
+	var decodeErr *pokeapi.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("expected a decode error, got %v", err)
+	}
+}
+
+func TestCatchPokemonErrors(t *testing.T) {
+	client := newErrorClient(t)
+	var decodeErr *pokeapi.DecodeError
+	cases := []struct {
+		name  string
+		check func(error) bool
+	}{
+		{name: "missingno", check: func(err error) bool { return errors.Is(err, pokeapi.ErrNotFound) }},
+		{name: "glitch", check: func(err error) bool { return errors.As(err, &decodeErr) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pokemon, caught, err := CatchPokemon(context.Background(), client, c.name)
+			if !c.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if pokemon != nil || caught {
+				t.Errorf("expected nothing to be caught, got %+v", pokemon)
+			}
+		})
+	}
+}
+
+func TestGetLocationAreasMaxConcurrency(t *testing.T) {
+	const areas, maxConcurrency = 12, 3
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/location-area/" {
+			list := NamedAPIResourceList{Count: areas}
+			for i := 0; i < areas; i++ {
+				list.Results = append(list.Results, NamedAPIResource{Name: fmt.Sprintf("area-%d", i)})
+			}
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+		n := inFlight.Add(1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		// Hold the request long enough for the others to pile up
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-1)
+		fmt.Fprint(w, `{"name": "area"}`)
+	}))
+	defer server.Close()
+	cache := pokecache.NewCache(time.Minute)
+	defer cache.Close()
+	client := pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()), pokeapi.WithMaxConcurrency(maxConcurrency))
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+
+	page, err := GetLocationAreas(context.Background(), client, &config, "forward")
+	if err != nil || page.Err != nil || len(page.Areas) != areas {
+		t.Fatalf("expected %d areas, got %d, %v, %v", areas, len(page.Areas), err, page.Err)
+	}
+	if got := peak.Load(); got > maxConcurrency {
+		t.Errorf("expected at most %d requests in flight, got a peak of %d", maxConcurrency, got)
+	} else if got < 2 {
+		t.Errorf("expected the areas to be fetched in parallel, got a peak of %d", got)
+	}
+}
+
+func TestGetLocationAreasCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/location-area/" {
+			fmt.Fprint(w, `{"count": 2, "next": "next-page", "results": [{"name": "canalave-city-area"}, {"name": "eterna-city-area"}]}`)
+			return
+		}
+		// The user presses Ctrl-C while the details are being fetched
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	cache := pokecache.NewCache(time.Minute)
+	defer cache.Close()
+	client := pokeapi.NewClient(server.URL, cache, pokeapi.WithHTTPClient(server.Client()))
+	first := client.LocationAreasURL()
+	config := UrlConfig{Next: &first}
+
+	page, err := GetLocationAreas(ctx, client, &config, "forward")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation to be returned, got %v", err)
+	}
+	if len(page.Names) != 0 || len(page.Failed) != 0 {
+		t.Errorf("expected no page to be shown, got %+v", page)
+	}
+	if config.Next == nil || *config.Next != first {
+		t.Errorf("expected the cursor to stay on the first page, got %v", config.Next)
+	}
+}